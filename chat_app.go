@@ -13,18 +13,20 @@ import (
 
 // ChatApp manages the chat-mode application lifecycle
 type ChatApp struct {
-	ctx       context.Context
-	mode      *service.ModeService
-	settings  *service.SettingsService
-	chat      *service.ChatService
-	mcp       *service.MCPManager
-	session   *service.SessionService
-	workDir   string
-	sessionID string
+	ctx         context.Context
+	mode        *service.ModeService
+	settings    *service.SettingsService
+	chat        *service.ChatService
+	mcp         *service.MCPManager
+	session     *service.SessionService
+	ext         *service.ExtensionService
+	workDir     string
+	sessionID   string
+	includeDirs []string
 }
 
 // NewChatApp creates a new chat-mode application
-func NewChatApp(workDir, sessionID string) *ChatApp {
+func NewChatApp(workDir, sessionID string, includeDirs []string) *ChatApp {
 	// Change to the working directory so config.Load() picks up project-local settings
 	// and MCP servers inherit the correct cwd
 	if workDir != "" {
@@ -35,17 +37,20 @@ func NewChatApp(workDir, sessionID string) *ChatApp {
 	mcpMgr := service.NewMCPManager(settings)
 	chat := service.NewChatService(settings, mcpMgr)
 	session := service.NewSessionService(chat)
+	ext := service.NewExtensionService(settings, mcpMgr)
 
 	mode := service.NewModeService("chat", workDir, sessionID)
 
 	return &ChatApp{
-		mode:      mode,
-		settings:  settings,
-		chat:      chat,
-		mcp:       mcpMgr,
-		session:   session,
-		workDir:   workDir,
-		sessionID: sessionID,
+		mode:        mode,
+		settings:    settings,
+		chat:        chat,
+		mcp:         mcpMgr,
+		session:     session,
+		ext:         ext,
+		workDir:     workDir,
+		sessionID:   sessionID,
+		includeDirs: includeDirs,
 	}
 }
 
@@ -56,6 +61,7 @@ func (a *ChatApp) startup(ctx context.Context) {
 	a.chat.SetContext(ctx)
 	a.mcp.SetContext(ctx)
 	a.session.SetContext(ctx)
+	a.ext.SetContext(ctx)
 
 	// Initialize settings and auth
 	if err := a.settings.Initialize(); err != nil {
@@ -79,6 +85,13 @@ func (a *ChatApp) startup(ctx context.Context) {
 		a.chat.SetWorkDir(a.workDir)
 	}
 
+	// Add --include-directories on top of the roots restored with the session
+	for _, dir := range a.includeDirs {
+		if err := a.chat.AddIncludeDirectory(dir); err != nil {
+			fmt.Printf("Failed to include directory %s: %v\n", dir, err)
+		}
+	}
+
 	// Auto-connect configured MCP servers
 	go a.mcp.ConnectAll()
 }
@@ -91,6 +104,7 @@ func (a *ChatApp) shutdown(ctx context.Context) {
 			fmt.Printf("Failed to save session on shutdown: %v\n", err)
 		}
 	}
+	a.chat.Shutdown()
 	a.mcp.DisconnectAll()
 }
 
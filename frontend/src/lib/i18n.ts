@@ -32,6 +32,30 @@ const messages: Record<Locale, Record<string, string>> = {
     'chat.emptySubtitle': 'Start a conversation with Gemini',
     'chat.emptyModel': 'Model',
     'chat.thinking': 'Thinking...',
+    'chat.addIncludeDir': 'Add workspace directory',
+    'chat.removeIncludeDir': 'Remove workspace directory',
+    'chat.toolRunning': 'Running',
+    'chat.showFullOutput': 'Show full output',
+    'chat.showShortOutput': 'Show shortened output',
+    'chat.fullOutputUnavailable': 'The full output is no longer available',
+    'chat.thoughts': 'Thoughts',
+    'chat.thinking': 'Thinking budget for this session',
+    'chat.thinkingDefault': 'Thinking: default',
+    'chat.thinkingDynamic': 'Thinking: auto',
+    'chat.thinkingOff': 'Thinking: off',
+    'chat.thinkingLow': 'Thinking: low',
+    'chat.thinkingMedium': 'Thinking: medium',
+    'chat.thinkingHigh': 'Thinking: high',
+    'finish.MAX_TOKENS': 'The response was cut off at the maximum output length',
+    'finish.SAFETY': 'The response was stopped by safety filters',
+    'finish.RECITATION': 'The response was stopped because it repeated copyrighted material',
+    'finish.promptBlocked': 'The request was blocked by safety filters',
+    'finish.other': 'The response ended unexpectedly',
+    'diff.added': 'New',
+    'diff.modified': 'Edit',
+    'diff.deleted': 'Delete',
+    'diff.renamed': 'Rename',
+    'diff.truncated': 'Diff truncated',
     'settings.title': 'Settings',
     'settings.defaultModel': 'Default Model',
     'settings.defaultModelDesc': 'New chats will start with this model',
@@ -53,6 +77,16 @@ const messages: Record<Locale, Record<string, string>> = {
     'mcp.connect': 'Connect',
     'mcp.disconnect': 'Disconnect',
     'mcp.toolsAvailable': 'tools available',
+    'extensions.title': 'Extensions',
+    'extensions.none': 'No extensions installed.',
+    'extensions.installHint': 'Extensions are loaded from ~/.gemini/extensions',
+    'extensions.enable': 'Enable here',
+    'extensions.disable': 'Disable here',
+    'extensions.excludes': 'Excluded tools',
+    'extensions.install': 'Install',
+    'extensions.update': 'Update',
+    'extensions.uninstall': 'Uninstall',
+    'extensions.sourcePlaceholder': 'Local path or git URL',
     'launcher.title': 'Recent Projects',
     'launcher.newProject': 'Open Directory',
     'launcher.noProjects': 'No recent projects',
@@ -77,6 +111,30 @@ const messages: Record<Locale, Record<string, string>> = {
     'chat.emptySubtitle': 'Geminiと会話を始めましょう',
     'chat.emptyModel': 'モデル',
     'chat.thinking': '考え中...',
+    'chat.addIncludeDir': 'ワークスペースにディレクトリを追加',
+    'chat.removeIncludeDir': 'ワークスペースからディレクトリを削除',
+    'chat.toolRunning': '実行中',
+    'chat.showFullOutput': '全出力を表示',
+    'chat.showShortOutput': '省略した出力を表示',
+    'chat.fullOutputUnavailable': '全出力はもう保存されていません',
+    'chat.thoughts': '思考',
+    'chat.thinking': 'このセッションの思考量',
+    'chat.thinkingDefault': '思考: デフォルト',
+    'chat.thinkingDynamic': '思考: 自動',
+    'chat.thinkingOff': '思考: オフ',
+    'chat.thinkingLow': '思考: 少',
+    'chat.thinkingMedium': '思考: 中',
+    'chat.thinkingHigh': '思考: 多',
+    'finish.MAX_TOKENS': '最大出力長に達したため応答が途中で終了しました',
+    'finish.SAFETY': '安全フィルタにより応答が停止されました',
+    'finish.RECITATION': '著作物の引用を含むため応答が停止されました',
+    'finish.promptBlocked': '安全フィルタによりリクエストがブロックされました',
+    'finish.other': '応答が予期せず終了しました',
+    'diff.added': '新規',
+    'diff.modified': '編集',
+    'diff.deleted': '削除',
+    'diff.renamed': '名前変更',
+    'diff.truncated': '差分が長いため省略されました',
     'settings.title': '設定',
     'settings.defaultModel': 'デフォルトモデル',
     'settings.defaultModelDesc': '新しいチャットはこのモデルで開始されます',
@@ -98,6 +156,16 @@ const messages: Record<Locale, Record<string, string>> = {
     'mcp.connect': '接続',
     'mcp.disconnect': '切断',
     'mcp.toolsAvailable': 'ツール利用可能',
+    'extensions.title': '拡張機能',
+    'extensions.none': '拡張機能がインストールされていません。',
+    'extensions.installHint': '拡張機能は ~/.gemini/extensions から読み込まれます',
+    'extensions.enable': 'このディレクトリで有効化',
+    'extensions.disable': 'このディレクトリで無効化',
+    'extensions.excludes': '除外ツール',
+    'extensions.install': 'インストール',
+    'extensions.update': '更新',
+    'extensions.uninstall': 'アンインストール',
+    'extensions.sourcePlaceholder': 'ローカルパスまたは git URL',
     'launcher.title': '最近のプロジェクト',
     'launcher.newProject': 'ディレクトリを開く',
     'launcher.noProjects': 'プロジェクトがありません',
@@ -13,6 +13,11 @@ const router = createRouter({
       name: 'mcp',
       component: () => import('../views/MCPView.vue'),
     },
+    {
+      path: '/extensions',
+      name: 'extensions',
+      component: () => import('../views/ExtensionsView.vue'),
+    },
     {
       path: '/prompts',
       name: 'prompts',
@@ -1,28 +1,49 @@
 import { defineStore } from 'pinia'
 import { ref } from 'vue'
-import { SendMessage, StopGeneration, ClearHistory, GetMessages, GetModel, SetModel, GetWorkDir, SetWorkDir, SubmitAskUserResponse, GetPlanMode, SetPlanMode } from '../../wailsjs/go/service/ChatService'
+import { SendMessage, StopGeneration, ClearHistory, GetMessages, GetModel, SetModel, GetThinkingConfig, SetThinkingConfig, GetWorkDir, SetWorkDir, GetIncludeDirectories, AddIncludeDirectory, RemoveIncludeDirectory, SubmitAskUserResponse, GetPlanMode, SetPlanMode, ListCommands, RunCommand } from '../../wailsjs/go/service/ChatService'
 import { GetUsage } from '../../wailsjs/go/service/SettingsService'
 import { SaveCurrentSession } from '../../wailsjs/go/service/SessionService'
 import { EventsOn } from '../../wailsjs/runtime/runtime'
-import type { service } from '../../wailsjs/go/models'
+import type { api, service } from '../../wailsjs/go/models'
 
 export interface StreamEvent {
-  type: 'start' | 'content' | 'tool_call' | 'tool_result' | 'done' | 'error'
+  type: 'start' | 'thought' | 'content' | 'tool_call' | 'tool_result' | 'done' | 'error'
   text?: string
   toolName?: string
   toolArgs?: string
+  callId?: string
+  diffs?: service.FileDiff[]
+  finishReason?: string
 }
 
+export interface ToolOutputEvent {
+  callId: string
+  toolName: string
+  text: string
+}
+
+// Keep only the tail of long running output in memory
+const MAX_TOOL_OUTPUT = 100_000
+
 // Auto-save callback set by App.vue
 let autoSaveSessionId: (() => string | null) | null = null
 
 export const useChatStore = defineStore('chat', () => {
   const messages = ref<service.ChatMessage[]>([])
   const streamingText = ref('')
+  const streamingThoughts = ref('')
   const isStreaming = ref(false)
   const error = ref<string | null>(null)
   const sessionModel = ref('')
+  // thinking budget of the session as a select value ('' = model default)
+  const thinkingBudget = ref('')
   const workDir = ref('')
+  const includeDirs = ref<string[]>([])
+
+  // live output of the running tool (run_shell_command)
+  const toolOutput = ref('')
+  const toolOutputName = ref('')
+  const toolOutputCallId = ref('')
 
   // ask_user dialog state
   const askUserVisible = ref(false)
@@ -45,18 +66,28 @@ export const useChatStore = defineStore('chat', () => {
         case 'start':
           isStreaming.value = true
           streamingText.value = ''
+          streamingThoughts.value = ''
           error.value = null
           break
+        case 'thought':
+          streamingThoughts.value += event.text || ''
+          break
         case 'content':
           streamingText.value += event.text || ''
           break
         case 'tool_call':
           break
         case 'tool_result':
+          // Calls run in parallel may finish while another one streams output
+          if (!event.callId || event.callId === toolOutputCallId.value) {
+            toolOutput.value = ''
+          }
           break
         case 'done':
           isStreaming.value = false
           streamingText.value = ''
+          streamingThoughts.value = ''
+          toolOutput.value = ''
           // Auto-save session
           if (autoSaveSessionId) {
             const id = autoSaveSessionId()
@@ -67,6 +98,8 @@ export const useChatStore = defineStore('chat', () => {
           isStreaming.value = false
           error.value = event.text || 'Unknown error'
           streamingText.value = ''
+          streamingThoughts.value = ''
+          toolOutput.value = ''
           break
       }
     })
@@ -75,6 +108,15 @@ export const useChatStore = defineStore('chat', () => {
       messages.value = msgs ?? []
     })
 
+    EventsOn('chat:tool_output', (event: ToolOutputEvent) => {
+      if (event.callId !== toolOutputCallId.value) {
+        toolOutputCallId.value = event.callId
+        toolOutput.value = ''
+      }
+      toolOutputName.value = event.toolName
+      toolOutput.value = (toolOutput.value + event.text).slice(-MAX_TOOL_OUTPUT)
+    })
+
     EventsOn('chat:ask_user', (questions: any[]) => {
       askUserQuestions.value = questions
       askUserVisible.value = true
@@ -96,8 +138,35 @@ export const useChatStore = defineStore('chat', () => {
     sessionModel.value = model
   }
 
+  async function fetchThinkingConfig() {
+    const cfg = await GetThinkingConfig()
+    thinkingBudget.value = cfg?.thinkingBudget != null ? String(cfg.thinkingBudget) : ''
+  }
+
+  async function changeThinkingBudget(value: string) {
+    // Thoughts are shown whenever the session sets a budget that allows thinking
+    const cfg: api.ThinkingConfig | null = value === '' ? null : { thinkingBudget: Number(value), includeThoughts: value !== '0' }
+    await SetThinkingConfig(cfg as api.ThinkingConfig)
+    thinkingBudget.value = value
+  }
+
   async function fetchWorkDir() {
     workDir.value = await GetWorkDir()
+    includeDirs.value = (await GetIncludeDirectories()) || []
+  }
+
+  async function addIncludeDir(dir: string) {
+    try {
+      await AddIncludeDirectory(dir)
+      includeDirs.value = (await GetIncludeDirectories()) || []
+    } catch (e: any) {
+      error.value = e?.message || String(e)
+    }
+  }
+
+  async function removeIncludeDir(dir: string) {
+    await RemoveIncludeDirectory(dir)
+    includeDirs.value = includeDirs.value.filter((d) => d !== dir)
   }
 
   async function changeWorkDir(dir: string) {
@@ -121,6 +190,23 @@ export const useChatStore = defineStore('chat', () => {
       }
       return true
     }
+
+    // Custom commands from extensions: "/name args..."
+    const match = text.trim().match(/^\/(\S+)\s*([\s\S]*)$/)
+    if (match) {
+      const commands = (await ListCommands()) ?? []
+      if (commands.some(c => c.name === match[1])) {
+        isStreaming.value = true
+        streamingText.value = ''
+        try {
+          await RunCommand(match[1], match[2])
+        } catch (e) {
+          error.value = String(e)
+          isStreaming.value = false
+        }
+        return true
+      }
+    }
     return false
   }
 
@@ -234,6 +320,7 @@ export const useChatStore = defineStore('chat', () => {
   async function stop() {
     await StopGeneration()
     isStreaming.value = false
+    toolOutput.value = ''
   }
 
   async function clear() {
@@ -242,7 +329,9 @@ export const useChatStore = defineStore('chat', () => {
     streamingText.value = ''
     error.value = null
     sessionModel.value = ''
+    thinkingBudget.value = ''
     workDir.value = ''
+    includeDirs.value = []
     await fetchSessionModel()
   }
 
@@ -263,10 +352,15 @@ export const useChatStore = defineStore('chat', () => {
   return {
     messages,
     streamingText,
+    streamingThoughts,
     isStreaming,
     error,
     sessionModel,
+    thinkingBudget,
     workDir,
+    includeDirs,
+    toolOutput,
+    toolOutputName,
     askUserVisible,
     askUserQuestions,
     planMode,
@@ -276,8 +370,12 @@ export const useChatStore = defineStore('chat', () => {
     setAutoSaveCallback,
     fetchSessionModel,
     changeSessionModel,
+    fetchThinkingConfig,
+    changeThinkingBudget,
     fetchWorkDir,
     changeWorkDir,
+    addIncludeDir,
+    removeIncludeDir,
     send,
     sendWithFiles,
     stop,
@@ -0,0 +1,83 @@
+import { defineStore } from 'pinia'
+import { ref } from 'vue'
+import {
+  ListExtensions,
+  SetExtensionEnabled,
+  InstallExtension,
+  UpdateExtension,
+  UninstallExtension,
+} from '../../wailsjs/go/service/ExtensionService'
+import { EventsOn } from '../../wailsjs/runtime/runtime'
+import type { config } from '../../wailsjs/go/models'
+
+export const useExtensionsStore = defineStore('extensions', () => {
+  const extensions = ref<config.Extension[]>([])
+  const loading = ref(false)
+  const error = ref<string | null>(null)
+  const status = ref<string | null>(null)
+
+  function setupEvents() {
+    EventsOn('extensions:updated', (updated: config.Extension[]) => {
+      extensions.value = updated ?? []
+    })
+  }
+
+  async function fetchExtensions() {
+    loading.value = true
+    try {
+      extensions.value = (await ListExtensions()) ?? []
+    } finally {
+      loading.value = false
+    }
+  }
+
+  async function setEnabled(name: string, enabled: boolean) {
+    await SetExtensionEnabled(name, enabled)
+    await fetchExtensions()
+  }
+
+  async function run(action: () => Promise<string | null>) {
+    loading.value = true
+    error.value = null
+    status.value = null
+    try {
+      status.value = await action()
+      await fetchExtensions()
+    } catch (e) {
+      error.value = String(e)
+    } finally {
+      loading.value = false
+    }
+  }
+
+  async function install(source: string) {
+    await run(async () => {
+      const ext = await InstallExtension(source)
+      return `Installed ${ext.name}`
+    })
+  }
+
+  async function update(name: string) {
+    await run(() => UpdateExtension(name))
+  }
+
+  async function uninstall(name: string) {
+    await run(async () => {
+      await UninstallExtension(name)
+      return `Uninstalled ${name}`
+    })
+  }
+
+  return {
+    extensions,
+    loading,
+    error,
+    status,
+    setupEvents,
+    fetchExtensions,
+    setEnabled,
+    install,
+    update,
+    uninstall,
+  }
+})
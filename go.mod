@@ -2,7 +2,10 @@ module github.com/tomohiro-owada/gmn-gui
 
 go 1.23
 
-require github.com/wailsapp/wails/v2 v2.11.0
+require (
+	github.com/wailsapp/wails/v2 v2.11.0
+	mvdan.cc/sh/v3 v3.11.0
+)
 
 require (
 	github.com/bep/debounce v1.2.1 // indirect
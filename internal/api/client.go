@@ -117,6 +117,17 @@ type GenerationConfig struct {
 	TopP            float64 `json:"topP,omitempty"`
 	TopK            int     `json:"topK,omitempty"`
 	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+
+	ThinkingConfig *ThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+// ThinkingConfig controls how much the model thinks before answering and
+// whether its thoughts are returned
+type ThinkingConfig struct {
+	// ThinkingBudget is the number of thinking tokens; 0 turns thinking off
+	// and -1 lets the model decide. Nil uses the model default.
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
 }
 
 // Tool represents a tool definition
@@ -147,14 +158,31 @@ type GenerateResponse struct {
 
 // InnerResponse is the inner response structure for Code Assist API
 type InnerResponse struct {
-	Candidates    []Candidate   `json:"candidates"`
-	UsageMetadata UsageMetadata `json:"usageMetadata"`
+	Candidates     []Candidate     `json:"candidates"`
+	UsageMetadata  UsageMetadata   `json:"usageMetadata"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
 }
 
 // Candidate represents a response candidate
 type Candidate struct {
-	Content      Content `json:"content"`
-	FinishReason string  `json:"finishReason"`
+	Content       Content        `json:"content"`
+	FinishReason  string         `json:"finishReason"` // "STOP" | "MAX_TOKENS" | "SAFETY" | "RECITATION" | ...
+	FinishMessage string         `json:"finishMessage,omitempty"`
+	SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// SafetyRating is the probability that content falls into a harm category
+type SafetyRating struct {
+	Category    string `json:"category"`    // e.g. "HARM_CATEGORY_DANGEROUS_CONTENT"
+	Probability string `json:"probability"` // "NEGLIGIBLE" | "LOW" | "MEDIUM" | "HIGH"
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// PromptFeedback reports whether the prompt itself was blocked
+type PromptFeedback struct {
+	BlockReason        string         `json:"blockReason,omitempty"`
+	BlockReasonMessage string         `json:"blockReasonMessage,omitempty"`
+	SafetyRatings      []SafetyRating `json:"safetyRatings,omitempty"`
 }
 
 // UsageMetadata holds token usage information
@@ -227,6 +255,12 @@ type StreamEvent struct {
 	ToolResult       *ToolResult    `json:"tool_result,omitempty"`
 	Usage            *UsageMetadata `json:"usage,omitempty"`
 	Error            string         `json:"error,omitempty"`
+
+	// Set on the done event
+	FinishReason   string          `json:"finish_reason,omitempty"`
+	FinishMessage  string          `json:"finish_message,omitempty"`
+	SafetyRatings  []SafetyRating  `json:"safety_ratings,omitempty"`
+	PromptFeedback *PromptFeedback `json:"prompt_feedback,omitempty"`
 }
 
 // ToolResult represents a tool execution result
@@ -421,6 +455,7 @@ func (c *Client) GenerateStream(ctx context.Context, req *GenerateRequest) (<-ch
 
 		reader := bufio.NewReader(resp.Body)
 		var usage *UsageMetadata
+		done := StreamEvent{Type: "done"}
 
 		for {
 			line, err := reader.ReadString('\n')
@@ -450,9 +485,19 @@ func (c *Client) GenerateStream(ctx context.Context, req *GenerateRequest) (<-ch
 			if chunk.Response.UsageMetadata.TotalTokenCount > 0 {
 				usage = &chunk.Response.UsageMetadata
 			}
+			if chunk.Response.PromptFeedback != nil {
+				done.PromptFeedback = chunk.Response.PromptFeedback
+			}
 
 			// Extract text from candidates
 			for _, candidate := range chunk.Response.Candidates {
+				if candidate.FinishReason != "" {
+					done.FinishReason = candidate.FinishReason
+					done.FinishMessage = candidate.FinishMessage
+				}
+				if len(candidate.SafetyRatings) > 0 {
+					done.SafetyRatings = candidate.SafetyRatings
+				}
 				for _, part := range candidate.Content.Parts {
 					if part.Text != "" {
 						if part.Thought {
@@ -473,7 +518,8 @@ func (c *Client) GenerateStream(ctx context.Context, req *GenerateRequest) (<-ch
 		}
 
 		// Send done event
-		events <- StreamEvent{Type: "done", Usage: usage}
+		done.Usage = usage
+		events <- done
 	}()
 
 	return events, nil
@@ -0,0 +1,132 @@
+// Custom command loading (TOML files under commands/).
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// loadCommands reads every *.toml file below dir. Subdirectories become
+// namespaces, so commands/git/commit.toml is registered as "git:commit".
+func loadCommands(dir, extension string) []Command {
+	var commands []Command
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".toml") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		values, err := parseCommandTOML(string(data))
+		if err != nil || values["prompt"] == "" {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		name := strings.TrimSuffix(filepath.ToSlash(rel), ".toml")
+		commands = append(commands, Command{
+			Name:        strings.ReplaceAll(name, "/", ":"),
+			Description: values["description"],
+			Prompt:      values["prompt"],
+			Extension:   extension,
+		})
+		return nil
+	})
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// parseCommandTOML parses the flat subset of TOML used by command files:
+// top-level keys with basic, literal and multi-line string values.
+func parseCommandTOML(src string) (map[string]string, error) {
+	values := make(map[string]string)
+	rest := src
+
+	for rest != "" {
+		var line string
+		if i := strings.IndexByte(rest, '\n'); i >= 0 {
+			line, rest = rest[:i], rest[i+1:]
+		} else {
+			line, rest = rest, ""
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		eq := strings.IndexByte(trimmed, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid line: %q", trimmed)
+		}
+		key := strings.Trim(strings.TrimSpace(trimmed[:eq]), `"`)
+		value := strings.TrimSpace(trimmed[eq+1:])
+
+		switch {
+		case strings.HasPrefix(value, `"""`), strings.HasPrefix(value, `'''`):
+			delim := value[:3]
+			body := value[3:] + "\n" + rest
+			end := strings.Index(body, delim)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated multi-line string for %q", key)
+			}
+			s := strings.TrimPrefix(body[:end], "\n")
+			if delim == `"""` {
+				s = unescapeTOML(s)
+			}
+			values[key] = s
+			rest = body[end+3:]
+			if i := strings.IndexByte(rest, '\n'); i >= 0 {
+				rest = rest[i+1:]
+			} else {
+				rest = ""
+			}
+		case strings.HasPrefix(value, `"`):
+			end := closingQuote(value[1:])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string for %q", key)
+			}
+			values[key] = unescapeTOML(value[1 : end+1])
+		case strings.HasPrefix(value, `'`):
+			end := strings.IndexByte(value[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string for %q", key)
+			}
+			values[key] = value[1 : end+1]
+		default:
+			// Non-string values (numbers, booleans) are not used by commands
+			values[key] = value
+		}
+	}
+
+	return values, nil
+}
+
+// closingQuote returns the index of the first unescaped double quote in s
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeTOML(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	r := strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n", `\t`, "\t", `\r`, "\r")
+	return r.Replace(s)
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want map[string]string
+	}{
+		{
+			name: "basic and literal strings",
+			src:  "description = \"Review code\"\nprompt = 'Check {{args}}'\n",
+			want: map[string]string{"description": "Review code", "prompt": "Check {{args}}"},
+		},
+		{
+			name: "comments and blank lines",
+			src:  "# a comment\n\n  # indented comment\ndescription = \"x\"\n",
+			want: map[string]string{"description": "x"},
+		},
+		{
+			name: "escapes in basic strings",
+			src:  `prompt = "say \"hi\"\tthen\\n stop\n"`,
+			want: map[string]string{"prompt": "say \"hi\"\tthen\\n stop\n"},
+		},
+		{
+			name: "literal strings keep backslashes",
+			src:  `prompt = 'C:\path\n'`,
+			want: map[string]string{"prompt": `C:\path\n`},
+		},
+		{
+			name: "multi-line basic string",
+			src:  "prompt = \"\"\"\nLine one\n  \"quoted\" # not a comment\nLine \\\"three\\\"\n\"\"\"\ndescription = \"after\"\n",
+			want: map[string]string{"prompt": "Line one\n  \"quoted\" # not a comment\nLine \"three\"\n", "description": "after"},
+		},
+		{
+			name: "multi-line literal string",
+			src:  "prompt = '''\nraw \\n text\n'''\n",
+			want: map[string]string{"prompt": "raw \\n text\n"},
+		},
+		{
+			name: "multi-line string on one line",
+			src:  `prompt = """inline"""`,
+			want: map[string]string{"prompt": "inline"},
+		},
+		{
+			name: "quoted key and non-string value",
+			src:  "\"prompt\" = \"x\"\nenabled = true\n",
+			want: map[string]string{"prompt": "x", "enabled": "true"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseCommandTOML(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, src := range []string{
+		"prompt = \"\"\"\nnever closed\n",
+		`prompt = "unterminated`,
+		`prompt = 'unterminated`,
+		"not a key value line",
+	} {
+		if _, err := parseCommandTOML(src); err == nil {
+			t.Errorf("expected an error for %q", src)
+		}
+	}
+}
@@ -7,8 +7,10 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 const (
@@ -22,11 +24,35 @@ type Config struct {
 	MCPServers map[string]MCPServerConfig `json:"mcpServers"`
 	General    GeneralConfig              `json:"general"`
 	Output     OutputConfig               `json:"output"`
+	Model      ModelConfig                `json:"model"`
+	Tools      ToolsConfig                `json:"tools"`
+
+	// Hooks maps a lifecycle event (BeforeTool, AfterTool, BeforeModel,
+	// SessionStart, SessionEnd, Stop) to the commands run for it. The
+	// matchers of project settings run after those of global settings.
+	Hooks map[string][]HookMatcher `json:"hooks,omitempty"`
+
+	// Sandbox is the legacy top-level form of tools.sandbox
+	Sandbox *SandboxConfig `json:"sandbox,omitempty"`
+
+	// ExcludeTools lists tools hidden from the model (settings + enabled extensions)
+	ExcludeTools []string `json:"excludeTools,omitempty"`
+
+	// ToolPolicy holds the tool rules of each settings layer in the order they
+	// were loaded: global, project, then extensions (populated by Load)
+	ToolPolicy []ToolPolicyLayer `json:"-"`
+
+	// Extensions holds every installed extension, enabled or not (populated by Load)
+	Extensions []Extension `json:"-"`
 }
 
 // SecurityConfig holds security-related settings
 type SecurityConfig struct {
 	Auth AuthConfig `json:"auth"`
+
+	// AllowedDirectories lists directories outside the working directory that
+	// file tools may access (absolute, ~/..., or relative to the working directory)
+	AllowedDirectories []string `json:"allowedDirectories,omitempty"`
 }
 
 // AuthConfig holds authentication settings
@@ -52,6 +78,10 @@ type MCPServerConfig struct {
 	Trust        bool     `json:"trust,omitempty"`
 	IncludeTools []string `json:"includeTools,omitempty"`
 	ExcludeTools []string `json:"excludeTools,omitempty"`
+
+	// Extension is the extension that provides the server, or empty for a
+	// server configured in settings (populated by Load)
+	Extension string `json:"-"`
 }
 
 // GeneralConfig holds general settings
@@ -64,6 +94,160 @@ type OutputConfig struct {
 	Format string `json:"format"`
 }
 
+// ModelConfig holds settings for the model conversation loop
+type ModelConfig struct {
+	// MaxToolRoundTrips bounds how often the model may be called back with
+	// tool results in one turn (default 100)
+	MaxToolRoundTrips int `json:"maxToolRoundTrips,omitempty"`
+
+	// DisableLoopDetection turns off stopping a turn when the model repeats
+	// the same tool call or text
+	DisableLoopDetection bool `json:"disableLoopDetection,omitempty"`
+
+	// MaxTokensContinuations is how often a response cut off by the output
+	// token limit is continued automatically in one turn (default 3, -1 to
+	// never continue)
+	MaxTokensContinuations int `json:"maxTokensContinuations,omitempty"`
+
+	// ToolResultAging shortens large tool results of earlier turns in the
+	// requests sent to the model
+	ToolResultAging ToolResultAgingConfig `json:"toolResultAging"`
+}
+
+// ToolResultAgingConfig controls when old tool results are replaced with
+// placeholders in model requests. Saved sessions keep the full results.
+type ToolResultAgingConfig struct {
+	// Disabled sends every tool result in full on every request
+	Disabled bool `json:"disabled,omitempty"`
+
+	// AfterTurns is how many user turns later a result is replaced (default 3)
+	AfterTurns int `json:"afterTurns,omitempty"`
+
+	// MinSize is the size in bytes from which a result, or a file such as an
+	// image read with it, is replaced (default 4096)
+	MinSize int `json:"minSize,omitempty"`
+
+	// Tools limits aging to these tools; "*" matches any characters
+	// (default: all tools)
+	Tools []string `json:"tools,omitempty"`
+}
+
+// ToolsConfig holds tool execution settings
+type ToolsConfig struct {
+	Sandbox *SandboxConfig `json:"sandbox,omitempty"`
+
+	// Allowed, if set, lists the only tools that may be used. Exclude lists
+	// tools that may not. A rule is a tool name (MCP tools as server__tool,
+	// "*" matches any characters) or a shell command prefix such as
+	// "run_shell_command(git status)". An allowed prefix ending in ">", like
+	// "run_shell_command(git log >)", also permits redirecting output to files.
+	Allowed []string `json:"allowed,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+
+	// DiscoveryCommand prints a JSON array of function declarations for
+	// project-specific tools; CallCommand runs one as `<callCommand> <name>`
+	// with the JSON arguments on standard input
+	DiscoveryCommand string `json:"discoveryCommand,omitempty"`
+	CallCommand      string `json:"callCommand,omitempty"`
+
+	// DangerousCommands decides what happens to shell commands classified as
+	// dangerous (rm -rf, git push, curl | sh): "ask" (default), "block" or "allow"
+	DangerousCommands string `json:"dangerousCommands,omitempty"`
+}
+
+// HookMatcher runs its hooks for events whose target (the tool name, or the
+// source of SessionStart) matches Matcher
+type HookMatcher struct {
+	Matcher string        `json:"matcher,omitempty"` // regular expression; empty or "*" matches everything
+	Hooks   []HookCommand `json:"hooks"`
+}
+
+// HookCommand is a command run for a hook event. It gets the event as JSON
+// on standard input; exit code 2 blocks the action with standard error as the
+// reason, and JSON printed on standard output can block or modify it.
+type HookCommand struct {
+	Type    string `json:"type,omitempty"` // "command" (the only type)
+	Command string `json:"command"`
+	Timeout int    `json:"timeout,omitempty"` // milliseconds (default 60000)
+}
+
+// ToolPolicyLayer is the tool rules one settings file or extension contributes
+type ToolPolicyLayer struct {
+	Source  string // "global settings", "project settings" or "extension <name>"
+	Path    string
+	Allowed []string
+	Exclude []string // tools.exclude and the legacy top-level excludeTools
+}
+
+// SandboxConfig controls sandboxed shell execution. In settings.json it may
+// be a boolean, a profile name ("bwrap", "unshare", "docker", "podman") or
+// an object with the fields below.
+type SandboxConfig struct {
+	Enabled bool   `json:"enabled"`
+	Profile string `json:"profile,omitempty"` // "auto" (default) picks bwrap or unshare
+	Network bool   `json:"network,omitempty"` // allow network access inside the sandbox
+	Image   string `json:"image,omitempty"`   // container image for docker/podman
+
+	// Env lists environment variable names passed into the sandbox in
+	// addition to PATH, HOME, LANG, TERM and the like
+	Env []string `json:"env,omitempty"`
+
+	// ReadWritePaths are writable in addition to the workspace directories
+	ReadWritePaths []string `json:"readWritePaths,omitempty"`
+}
+
+// UnmarshalJSON accepts true/false, a profile name or an object
+func (s *SandboxConfig) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*s = SandboxConfig{Enabled: b}
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = ParseSandbox(str)
+		return nil
+	}
+	type plain SandboxConfig
+	p := plain{Enabled: true}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("sandbox: expected a boolean, a profile name or an object: %w", err)
+	}
+	*s = SandboxConfig(p)
+	return nil
+}
+
+// ParseSandbox interprets a string setting such as the GEMINI_SANDBOX
+// environment variable: "true"/"1", "false"/"0" or a profile name
+func ParseSandbox(v string) SandboxConfig {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "false", "0", "off":
+		return SandboxConfig{}
+	case "true", "1", "on":
+		return SandboxConfig{Enabled: true}
+	default:
+		return SandboxConfig{Enabled: true, Profile: strings.ToLower(strings.TrimSpace(v))}
+	}
+}
+
+// SandboxSettings returns the effective sandbox settings: tools.sandbox or
+// the legacy top-level sandbox, with GEMINI_SANDBOX overriding whether it is
+// enabled and which profile is used
+func (c *Config) SandboxSettings() SandboxConfig {
+	var sb SandboxConfig
+	switch {
+	case c.Tools.Sandbox != nil:
+		sb = *c.Tools.Sandbox
+	case c.Sandbox != nil:
+		sb = *c.Sandbox
+	}
+	if v := os.Getenv("GEMINI_SANDBOX"); v != "" {
+		env := ParseSandbox(v)
+		sb.Enabled, sb.Profile = env.Enabled, env.Profile
+	}
+	return sb
+}
+
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -102,7 +286,7 @@ func Load() (*Config, error) {
 
 	// Load global settings
 	globalPath := filepath.Join(geminiPath, settingsFile)
-	if err := loadFile(globalPath, cfg); err != nil && !os.IsNotExist(err) {
+	if err := loadFile(globalPath, "global settings", cfg); err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
 
@@ -110,12 +294,12 @@ func Load() (*Config, error) {
 	cwd, err := os.Getwd()
 	if err == nil {
 		projectPath := filepath.Join(cwd, geminiDir, settingsFile)
-		if err := loadFile(projectPath, cfg); err != nil && !os.IsNotExist(err) {
+		if err := loadFile(projectPath, "project settings", cfg); err != nil && !os.IsNotExist(err) {
 			return nil, err
 		}
 	}
 
-	// Load extensions (MCP servers from ~/.gemini/extensions/*)
+	// Load extensions (MCP servers, context files, excluded tools, commands)
 	if err := loadExtensions(geminiPath, cwd, cfg); err != nil {
 		// Non-fatal: just skip extensions
 		_ = err
@@ -124,100 +308,45 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
-// geminiExtension represents a gemini-extension.json file
-type geminiExtension struct {
-	Name       string                    `json:"name"`
-	MCPServers map[string]MCPServerConfig `json:"mcpServers"`
-}
-
-// extensionEnablement maps extension name to enablement config
-type extensionEnablement struct {
-	Overrides []string `json:"overrides"`
-}
-
-func loadExtensions(geminiPath, cwd string, cfg *Config) error {
-	extDir := filepath.Join(geminiPath, "extensions")
-
-	// Load enablement config
-	enablement := make(map[string]extensionEnablement)
-	enablementPath := filepath.Join(extDir, "extension-enablement.json")
-	if data, err := os.ReadFile(enablementPath); err == nil {
-		_ = json.Unmarshal(data, &enablement)
-	}
-
-	// Scan extension directories
-	entries, err := os.ReadDir(extDir)
+// loadFile merges a settings file into cfg and records its tool rules as a
+// separate policy layer, since later files replace the lists in cfg. Its hook
+// matchers are appended to those of earlier files for the same event.
+func loadFile(path, source string, cfg *Config) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil // no extensions directory
-	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-		extName := entry.Name()
-		extPath := filepath.Join(extDir, extName)
-		manifestPath := filepath.Join(extPath, "gemini-extension.json")
-
-		data, err := os.ReadFile(manifestPath)
-		if err != nil {
-			continue
-		}
-
-		var ext geminiExtension
-		if err := json.Unmarshal(data, &ext); err != nil {
-			continue
-		}
-
-		// Check enablement: if there's an entry for this extension, check if cwd matches
-		if en, ok := enablement[extName]; ok {
-			if !isEnabledForDir(cwd, en.Overrides) {
-				continue
-			}
-		}
-
-		// Merge MCP servers from extension
-		for serverName, serverCfg := range ext.MCPServers {
-			// Resolve ${extensionPath} in CWD
-			if serverCfg.CWD == "${extensionPath}" {
-				serverCfg.CWD = extPath
-			}
-			// Don't override user-configured servers
-			if _, exists := cfg.MCPServers[serverName]; !exists {
-				cfg.MCPServers[serverName] = serverCfg
-			}
-		}
-	}
-
-	return nil
-}
-
-func isEnabledForDir(cwd string, overrides []string) bool {
-	if len(overrides) == 0 {
-		return true // no overrides = enabled everywhere
+		return err
 	}
-	for _, pattern := range overrides {
-		matched, err := filepath.Match(pattern, cwd)
-		if err == nil && matched {
-			return true
-		}
-		// Also check if cwd is under the pattern directory (glob * at end)
-		if len(pattern) > 0 && pattern[len(pattern)-1] == '*' {
-			prefix := pattern[:len(pattern)-1]
-			if len(cwd) >= len(prefix) && cwd[:len(prefix)] == prefix {
-				return true
-			}
+	hooks := cfg.Hooks
+	cfg.Hooks = nil
+	err = json.Unmarshal(data, cfg)
+	for event, matchers := range cfg.Hooks {
+		if hooks == nil {
+			hooks = make(map[string][]HookMatcher)
 		}
+		hooks[event] = append(hooks[event], matchers...)
 	}
-	return false
-}
-
-func loadFile(path string, cfg *Config) error {
-	data, err := os.ReadFile(path)
+	cfg.Hooks = hooks
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data, cfg)
+
+	var rules struct {
+		Tools struct {
+			Allowed []string `json:"allowed"`
+			Exclude []string `json:"exclude"`
+		} `json:"tools"`
+		ExcludeTools []string `json:"excludeTools"`
+	}
+	json.Unmarshal(data, &rules)
+	if exclude := append(rules.Tools.Exclude, rules.ExcludeTools...); len(rules.Tools.Allowed) > 0 || len(exclude) > 0 {
+		cfg.ToolPolicy = append(cfg.ToolPolicy, ToolPolicyLayer{
+			Source:  source,
+			Path:    path,
+			Allowed: rules.Tools.Allowed,
+			Exclude: exclude,
+		})
+	}
+	return nil
 }
 
 // CachedState represents cached state for geminimini
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFileHooks(t *testing.T) {
+	dir := t.TempDir()
+	global := filepath.Join(dir, "global.json")
+	project := filepath.Join(dir, "project.json")
+	os.WriteFile(global, []byte(`{"hooks": {
+		"BeforeTool": [{"matcher": "run_shell_command", "hooks": [{"command": "audit.sh"}]}],
+		"Stop": [{"hooks": [{"command": "notify.sh"}]}]
+	}}`), 0o644)
+	os.WriteFile(project, []byte(`{"hooks": {
+		"BeforeTool": [{"matcher": "write_file", "hooks": [{"command": "lint.sh"}]}]
+	}}`), 0o644)
+
+	cfg := DefaultConfig()
+	if err := loadFile(global, "global settings", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadFile(project, "project settings", cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	before := cfg.Hooks["BeforeTool"]
+	if len(before) != 2 || before[0].Hooks[0].Command != "audit.sh" || before[1].Hooks[0].Command != "lint.sh" {
+		t.Errorf("BeforeTool matchers not concatenated in load order: %+v", before)
+	}
+	if stop := cfg.Hooks["Stop"]; len(stop) != 1 || stop[0].Hooks[0].Command != "notify.sh" {
+		t.Errorf("global Stop hook lost: %+v", stop)
+	}
+}
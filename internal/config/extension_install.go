@@ -0,0 +1,319 @@
+// Extension install, update and uninstall.
+// This file was modified from the original Gemini CLI.
+// Copyright 2025 Google LLC
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/envutil"
+)
+
+// installMetadataFile is written into each installed extension, as Gemini CLI does
+const installMetadataFile = ".gemini-extension-install.json"
+
+// Install source types (same values as Gemini CLI)
+const (
+	InstallTypeGit   = "git"
+	InstallTypeLocal = "local"
+)
+
+var validExtensionName = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+// InstallMetadata records where an extension was installed from
+type InstallMetadata struct {
+	Source string `json:"source"`
+	Type   string `json:"type"` // "git" | "local"
+	Ref    string `json:"ref,omitempty"`
+}
+
+// IsGitSource reports whether source should be cloned rather than copied
+func IsGitSource(source string) bool {
+	for _, prefix := range []string{"http://", "https://", "git@", "file://", "ssh://", "sso://"} {
+		if strings.HasPrefix(source, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// InstallExtension clones (git URL) or copies (local directory) source into
+// ~/.gemini/extensions/<name>, validates gemini-extension.json and records
+// install metadata. It fails if an extension with the same name is installed.
+func InstallExtension(source string) (*Extension, error) {
+	extDir, err := ExtensionsDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(extDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create extensions directory: %w", err)
+	}
+
+	meta := InstallMetadata{Source: source, Type: InstallTypeLocal}
+	if IsGitSource(source) {
+		meta.Type = InstallTypeGit
+	} else {
+		abs, err := filepath.Abs(source)
+		if err != nil {
+			return nil, err
+		}
+		meta.Source = abs
+	}
+
+	staging, err := fetchExtension(extDir, meta)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := validateManifest(staging)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing, _ := findExtensionDir(extDir, manifest.Name); existing != "" {
+		return nil, fmt.Errorf("extension %q is already installed at %s", manifest.Name, existing)
+	}
+	dest := filepath.Join(extDir, manifest.Name)
+	if _, err := os.Stat(dest); err == nil {
+		return nil, fmt.Errorf("directory %s already exists", dest)
+	}
+
+	if err := writeInstallMetadata(staging, meta); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(staging, dest); err != nil {
+		return nil, fmt.Errorf("failed to move extension into place: %w", err)
+	}
+
+	ext, _, err := readExtension(dest)
+	return ext, err
+}
+
+// UpdateExtension re-fetches a git-installed extension and replaces its files.
+// It returns the versions before and after the update.
+func UpdateExtension(name string) (oldVersion, newVersion string, err error) {
+	extDir, err := ExtensionsDir()
+	if err != nil {
+		return "", "", err
+	}
+	dest, err := findExtensionDir(extDir, name)
+	if err != nil {
+		return "", "", err
+	}
+
+	meta, err := ReadInstallMetadata(dest)
+	if err != nil {
+		return "", "", fmt.Errorf("extension %q has no install metadata and cannot be updated: %w", name, err)
+	}
+	if meta.Type != InstallTypeGit {
+		return "", "", fmt.Errorf("extension %q was installed from %s (%s); only git installs can be updated", name, meta.Source, meta.Type)
+	}
+
+	current, err := validateManifest(dest)
+	if err != nil {
+		return "", "", err
+	}
+
+	staging, err := fetchExtension(extDir, *meta)
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(staging)
+
+	updated, err := validateManifest(staging)
+	if err != nil {
+		return "", "", err
+	}
+	if updated.Name != current.Name {
+		return "", "", fmt.Errorf("updated extension is named %q, expected %q", updated.Name, current.Name)
+	}
+	if err := writeInstallMetadata(staging, *meta); err != nil {
+		return "", "", err
+	}
+
+	// Swap directories so a failed rename leaves the old version in place.
+	// The backup is hidden so an interrupted update does not load it as a
+	// second copy of the extension.
+	backup := filepath.Join(extDir, "."+filepath.Base(dest)+".old")
+	os.RemoveAll(backup)
+	if err := os.Rename(dest, backup); err != nil {
+		return "", "", fmt.Errorf("failed to replace extension: %w", err)
+	}
+	if err := os.Rename(staging, dest); err != nil {
+		os.Rename(backup, dest)
+		return "", "", fmt.Errorf("failed to replace extension: %w", err)
+	}
+	os.RemoveAll(backup)
+
+	return current.Version, updated.Version, nil
+}
+
+// UninstallExtension removes an installed extension and its enablement rules
+func UninstallExtension(name string) error {
+	extDir, err := ExtensionsDir()
+	if err != nil {
+		return err
+	}
+	dir, err := findExtensionDir(extDir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove extension: %w", err)
+	}
+	return removeEnablement(extDir, name)
+}
+
+// ReadInstallMetadata reads .gemini-extension-install.json from an extension directory
+func ReadInstallMetadata(extPath string) (*InstallMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(extPath, installMetadataFile))
+	if err != nil {
+		return nil, err
+	}
+	var meta InstallMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func writeInstallMetadata(extPath string, meta InstallMetadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(extPath, installMetadataFile), data, 0o644)
+}
+
+// fetchExtension clones or copies the source into a staging directory inside
+// extDir (so the final rename stays on one filesystem) and returns its path.
+func fetchExtension(extDir string, meta InstallMetadata) (string, error) {
+	staging, err := os.MkdirTemp(extDir, ".install-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	switch meta.Type {
+	case InstallTypeGit:
+		err = gitClone(meta.Source, meta.Ref, staging)
+	default:
+		var info os.FileInfo
+		info, err = os.Stat(meta.Source)
+		if err == nil && !info.IsDir() {
+			err = fmt.Errorf("%s is not a directory", meta.Source)
+		}
+		if err == nil {
+			err = copyDir(meta.Source, staging)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		return "", err
+	}
+	return staging, nil
+}
+
+func gitClone(source, ref, dest string) error {
+	args := []string{"clone", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, source, dest)
+
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(envutil.ShellEnv(), "GIT_TERMINAL_PROMPT=0")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git clone %s failed: %v\n%s", source, err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// validateManifest checks that dir contains a usable gemini-extension.json
+func validateManifest(dir string) (*geminiExtension, error) {
+	data, err := os.ReadFile(filepath.Join(dir, extensionManifest))
+	if err != nil {
+		return nil, fmt.Errorf("%s not found in extension: %w", extensionManifest, err)
+	}
+	var manifest geminiExtension
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", extensionManifest, err)
+	}
+	if manifest.Name == "" {
+		return nil, fmt.Errorf("invalid %s: missing \"name\"", extensionManifest)
+	}
+	if !validExtensionName.MatchString(manifest.Name) {
+		return nil, fmt.Errorf("invalid extension name %q: only letters, numbers and dashes are allowed", manifest.Name)
+	}
+	return &manifest, nil
+}
+
+// findExtensionDir returns the directory of the installed extension with the given manifest name
+func findExtensionDir(extDir, name string) (string, error) {
+	entries, err := os.ReadDir(extDir)
+	if err != nil {
+		return "", fmt.Errorf("extension %q is not installed", name)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(extDir, entry.Name())
+		if ext, _, err := readExtension(path); err == nil && ext.Name == name {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("extension %q is not installed", name)
+}
+
+// copyDir recursively copies src into dst, skipping the .git directory
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			if info.Name() == ".git" && rel != "." {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
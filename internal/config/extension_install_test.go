@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func writeManifest(t *testing.T, dir, name, version string) {
+	t.Helper()
+	manifest := `{"name": "` + name + `", "version": "` + version + `"}`
+	if err := os.WriteFile(filepath.Join(dir, extensionManifest), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstallLocalExtension(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	src := t.TempDir()
+	writeManifest(t, src, "local-ext", "1.0.0")
+	os.WriteFile(filepath.Join(src, "GEMINI.md"), []byte("context"), 0o644)
+
+	ext, err := InstallExtension(src)
+	if err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+	if ext.Name != "local-ext" || len(ext.ContextFiles) != 1 {
+		t.Fatalf("unexpected extension: %+v", ext)
+	}
+
+	meta, err := ReadInstallMetadata(ext.Path)
+	if err != nil {
+		t.Fatalf("missing install metadata: %v", err)
+	}
+	if meta.Type != InstallTypeLocal || meta.Source != src {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+
+	if _, err := InstallExtension(src); err == nil {
+		t.Error("expected duplicate install to fail")
+	}
+	if _, _, err := UpdateExtension("local-ext"); err == nil {
+		t.Error("expected update of a local install to fail")
+	}
+
+	if err := UninstallExtension("local-ext"); err != nil {
+		t.Fatalf("uninstall failed: %v", err)
+	}
+	if _, err := os.Stat(ext.Path); !os.IsNotExist(err) {
+		t.Errorf("extension directory still exists after uninstall")
+	}
+}
+
+func TestInstallRejectsInvalidManifest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	src := t.TempDir()
+	if _, err := InstallExtension(src); err == nil {
+		t.Error("expected install without manifest to fail")
+	}
+
+	writeManifest(t, src, "../escape", "1.0.0")
+	if _, err := InstallExtension(src); err == nil {
+		t.Error("expected install with invalid name to fail")
+	}
+}
+
+func TestInstallAndUpdateGitExtension(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	git("init", "-q")
+	writeManifest(t, repo, "git-ext", "1.0.0")
+	git("add", ".")
+	git("commit", "-q", "-m", "v1")
+
+	source := "file://" + filepath.ToSlash(repo)
+	ext, err := InstallExtension(source)
+	if err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+	if ext.Version != "1.0.0" {
+		t.Errorf("expected version 1.0.0, got %q", ext.Version)
+	}
+	meta, err := ReadInstallMetadata(ext.Path)
+	if err != nil || meta.Type != InstallTypeGit || meta.Source != source {
+		t.Fatalf("unexpected metadata: %+v (%v)", meta, err)
+	}
+
+	writeManifest(t, repo, "git-ext", "1.1.0")
+	git("commit", "-q", "-am", "v1.1")
+
+	oldVersion, newVersion, err := UpdateExtension("git-ext")
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if oldVersion != "1.0.0" || newVersion != "1.1.0" {
+		t.Errorf("expected 1.0.0 -> 1.1.0, got %s -> %s", oldVersion, newVersion)
+	}
+	if _, err := ReadInstallMetadata(ext.Path); err != nil {
+		t.Errorf("install metadata lost after update: %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+// Gemini CLI extension loading.
+// This file was modified from the original Gemini CLI.
+// Copyright 2025 Google LLC
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	extensionsDir       = "extensions"
+	extensionManifest   = "gemini-extension.json"
+	enablementFile      = "extension-enablement.json"
+	defaultContextFile  = "GEMINI.md"
+	extensionCommandDir = "commands"
+)
+
+// Extension is an installed Gemini CLI extension
+type Extension struct {
+	Name         string    `json:"name"`
+	Version      string    `json:"version,omitempty"`
+	Path         string    `json:"path"`
+	Enabled      bool      `json:"enabled"`
+	ContextFiles []string  `json:"contextFiles,omitempty"` // absolute paths that exist on disk
+	ExcludeTools []string  `json:"excludeTools,omitempty"`
+	MCPServers   []string  `json:"mcpServers,omitempty"`
+	Commands     []Command `json:"commands,omitempty"`
+}
+
+// Command is a custom slash command defined by a TOML file
+type Command struct {
+	Name        string `json:"name"` // e.g. "git:commit" for commands/git/commit.toml
+	Description string `json:"description,omitempty"`
+	Prompt      string `json:"prompt"`
+	Extension   string `json:"extension,omitempty"`
+}
+
+// geminiExtension represents a gemini-extension.json file
+type geminiExtension struct {
+	Name            string                     `json:"name"`
+	Version         string                     `json:"version"`
+	MCPServers      map[string]MCPServerConfig `json:"mcpServers"`
+	ContextFileName json.RawMessage            `json:"contextFileName,omitempty"` // string or []string
+	ExcludeTools    []string                   `json:"excludeTools,omitempty"`
+}
+
+// contextFileNames returns the declared context file names, defaulting to GEMINI.md
+func (e *geminiExtension) contextFileNames() []string {
+	if len(e.ContextFileName) == 0 {
+		return []string{defaultContextFile}
+	}
+	var single string
+	if err := json.Unmarshal(e.ContextFileName, &single); err == nil {
+		return []string{single}
+	}
+	var many []string
+	if err := json.Unmarshal(e.ContextFileName, &many); err == nil {
+		return many
+	}
+	return []string{defaultContextFile}
+}
+
+// extensionEnablement maps extension name to enablement config
+type extensionEnablement struct {
+	Overrides []string `json:"overrides"`
+}
+
+// ExtensionsDir returns the path to ~/.gemini/extensions
+func ExtensionsDir() (string, error) {
+	geminiPath, err := GeminiDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(geminiPath, extensionsDir), nil
+}
+
+func loadExtensions(geminiPath, cwd string, cfg *Config) error {
+	extDir := filepath.Join(geminiPath, extensionsDir)
+
+	enablement := readEnablement(extDir)
+
+	// Scan extension directories
+	entries, err := os.ReadDir(extDir)
+	if err != nil {
+		return nil // no extensions directory
+	}
+
+	for _, entry := range entries {
+		// Skip files and in-progress installs (".install-*")
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		extPath := filepath.Join(extDir, entry.Name())
+
+		ext, manifest, err := readExtension(extPath)
+		if err != nil {
+			continue
+		}
+		ext.Enabled = isEnabledForDir(cwd, enablement[ext.Name].Overrides)
+		cfg.Extensions = append(cfg.Extensions, *ext)
+
+		if !ext.Enabled {
+			continue
+		}
+
+		cfg.ExcludeTools = append(cfg.ExcludeTools, ext.ExcludeTools...)
+		if len(ext.ExcludeTools) > 0 {
+			cfg.ToolPolicy = append(cfg.ToolPolicy, ToolPolicyLayer{
+				Source:  "extension " + ext.Name,
+				Path:    filepath.Join(extPath, extensionManifest),
+				Exclude: ext.ExcludeTools,
+			})
+		}
+
+		// Merge MCP servers from extension
+		for serverName, serverCfg := range manifest.MCPServers {
+			// Don't override user-configured servers
+			if _, exists := cfg.MCPServers[serverName]; !exists {
+				serverCfg = expandServerVars(serverCfg, extPath)
+				serverCfg.Extension = ext.Name
+				cfg.MCPServers[serverName] = serverCfg
+			}
+		}
+	}
+
+	sort.Slice(cfg.Extensions, func(i, j int) bool {
+		return cfg.Extensions[i].Name < cfg.Extensions[j].Name
+	})
+	return nil
+}
+
+// readExtension parses the manifest in extPath and collects its context files and commands
+func readExtension(extPath string) (*Extension, *geminiExtension, error) {
+	data, err := os.ReadFile(filepath.Join(extPath, extensionManifest))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var manifest geminiExtension
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, err
+	}
+	if manifest.Name == "" {
+		manifest.Name = filepath.Base(extPath)
+	}
+
+	ext := &Extension{
+		Name:         manifest.Name,
+		Version:      manifest.Version,
+		Path:         extPath,
+		ExcludeTools: manifest.ExcludeTools,
+	}
+
+	for _, name := range manifest.contextFileNames() {
+		p := filepath.Join(extPath, name)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			ext.ContextFiles = append(ext.ContextFiles, p)
+		}
+	}
+
+	for serverName := range manifest.MCPServers {
+		ext.MCPServers = append(ext.MCPServers, serverName)
+	}
+	sort.Strings(ext.MCPServers)
+
+	ext.Commands = loadCommands(filepath.Join(extPath, extensionCommandDir), manifest.Name)
+
+	return ext, &manifest, nil
+}
+
+// expandServerVars resolves ${extensionPath} and ${/} in an extension's MCP server config
+func expandServerVars(serverCfg MCPServerConfig, extPath string) MCPServerConfig {
+	expand := func(s string) string {
+		s = strings.ReplaceAll(s, "${extensionPath}", extPath)
+		return strings.ReplaceAll(s, "${/}", string(filepath.Separator))
+	}
+
+	serverCfg.Command = expand(serverCfg.Command)
+	serverCfg.CWD = expand(serverCfg.CWD)
+	if len(serverCfg.Args) > 0 {
+		args := make([]string, len(serverCfg.Args))
+		for i, a := range serverCfg.Args {
+			args[i] = expand(a)
+		}
+		serverCfg.Args = args
+	}
+	if len(serverCfg.Env) > 0 {
+		env := make(map[string]string, len(serverCfg.Env))
+		for k, v := range serverCfg.Env {
+			env[k] = expand(v)
+		}
+		serverCfg.Env = env
+	}
+	return serverCfg
+}
+
+func readEnablement(extDir string) map[string]extensionEnablement {
+	enablement := make(map[string]extensionEnablement)
+	if data, err := os.ReadFile(filepath.Join(extDir, enablementFile)); err == nil {
+		_ = json.Unmarshal(data, &enablement)
+	}
+	return enablement
+}
+
+// SetExtensionEnabled enables or disables an extension for dir and its subdirectories
+// by rewriting extension-enablement.json, using the same rule format as Gemini CLI
+// ("/path/*" enables, "!/path/*" disables, the last matching rule wins).
+func SetExtensionEnabled(name, dir string, enabled bool) error {
+	extDir, err := ExtensionsDir()
+	if err != nil {
+		return err
+	}
+
+	enablement := readEnablement(extDir)
+
+	rule := withTrailingSlash(dir) + "*"
+	var kept []string
+	for _, existing := range enablement[name].Overrides {
+		if strings.TrimPrefix(existing, "!") == rule {
+			continue
+		}
+		kept = append(kept, existing)
+	}
+	if !enabled {
+		rule = "!" + rule
+	}
+	enablement[name] = extensionEnablement{Overrides: append(kept, rule)}
+
+	return writeEnablement(extDir, enablement)
+}
+
+// removeEnablement drops all enablement rules for an extension
+func removeEnablement(extDir, name string) error {
+	enablement := readEnablement(extDir)
+	if _, ok := enablement[name]; !ok {
+		return nil
+	}
+	delete(enablement, name)
+	return writeEnablement(extDir, enablement)
+}
+
+func writeEnablement(extDir string, enablement map[string]extensionEnablement) error {
+	if err := os.MkdirAll(extDir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(enablement, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(extDir, enablementFile), data, 0o644)
+}
+
+// isEnabledForDir evaluates enablement rules for cwd. Extensions are enabled by
+// default; each matching rule flips the state, so the last match wins.
+func isEnabledForDir(cwd string, overrides []string) bool {
+	enabled := true
+	path := withTrailingSlash(cwd)
+	for _, rule := range overrides {
+		disable := strings.HasPrefix(rule, "!")
+		base := strings.TrimPrefix(rule, "!")
+		includeSubdirs := strings.HasSuffix(base, "*")
+		base = withTrailingSlash(strings.TrimSuffix(base, "*"))
+
+		matched := path == base
+		if includeSubdirs {
+			matched = strings.HasPrefix(path, base)
+		}
+		if matched {
+			enabled = !disable
+		}
+	}
+	return enabled
+}
+
+func withTrailingSlash(p string) string {
+	p = filepath.ToSlash(p)
+	if !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
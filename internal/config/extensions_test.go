@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsEnabledForDir(t *testing.T) {
+	tests := []struct {
+		cwd       string
+		overrides []string
+		want      bool
+	}{
+		{"/work/app", nil, true},
+		{"/work/app", []string{"!/work/*"}, false},
+		{"/work/app", []string{"!/work/app/*"}, false},
+		{"/work/app/sub", []string{"!/work/app/*"}, false},
+		{"/work/other", []string{"!/work/app/*"}, true},
+		{"/work/app-2", []string{"!/work/app/*"}, true}, // prefix must end at a path separator
+		// Without "*" a rule matches only the directory itself
+		{"/work/app", []string{"!/work/app"}, false},
+		{"/work/app/sub", []string{"!/work/app"}, true},
+		// The last matching rule wins
+		{"/work/app", []string{"!/work/*", "/work/app/*"}, true},
+		{"/work/app", []string{"/work/app/*", "!/work/*"}, false},
+		{"/work/app/sub", []string{"!/*", "/work/*", "!/work/app/sub/*"}, false},
+		{"/work/app", []string{"!/*", "/work/*", "!/work/app/sub/*"}, true},
+	}
+	for _, tt := range tests {
+		if got := isEnabledForDir(tt.cwd, tt.overrides); got != tt.want {
+			t.Errorf("isEnabledForDir(%q, %q) = %v, want %v", tt.cwd, tt.overrides, got, tt.want)
+		}
+	}
+}
+
+func TestSetExtensionEnabled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	extDir, err := ExtensionsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		dir     string
+		enabled bool
+		want    []string
+	}{
+		{"/work", false, []string{"!/work/*"}},
+		{"/work/app", true, []string{"!/work/*", "/work/app/*"}},
+		// Changing an existing rule moves it to the end so it wins
+		{"/work", true, []string{"/work/app/*", "/work/*"}},
+		{"/work/app/", false, []string{"/work/*", "!/work/app/*"}},
+	}
+	for _, step := range steps {
+		if err := SetExtensionEnabled("ext", step.dir, step.enabled); err != nil {
+			t.Fatal(err)
+		}
+		got := readEnablement(extDir)["ext"].Overrides
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("after SetExtensionEnabled(%q, %v): %q, want %q", step.dir, step.enabled, got, step.want)
+		}
+	}
+	if isEnabledForDir("/work/app/sub", readEnablement(extDir)["ext"].Overrides) {
+		t.Error("extension should be disabled below /work/app")
+	}
+	if !isEnabledForDir("/work/other", readEnablement(extDir)["ext"].Overrides) {
+		t.Error("extension should be enabled in /work/other")
+	}
+
+	if err := removeEnablement(extDir, "ext"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readEnablement(extDir)["ext"]; ok {
+		t.Error("rules still present after removeEnablement")
+	}
+}
+
+func TestLoadExtensionServerOwnership(t *testing.T) {
+	geminiPath := t.TempDir()
+	extPath := filepath.Join(geminiPath, extensionsDir, "ext")
+	if err := os.MkdirAll(extPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"name": "ext", "version": "1.0.0", "mcpServers": {
+		"shared": {"command": "ext-shared"},
+		"own": {"command": "ext-own"}
+	}}`
+	if err := os.WriteFile(filepath.Join(extPath, extensionManifest), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A backup left by an interrupted update is not a second extension
+	if err := os.MkdirAll(filepath.Join(geminiPath, extensionsDir, ".ext.old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{MCPServers: map[string]MCPServerConfig{"shared": {Command: "user-shared"}}}
+	if err := loadExtensions(geminiPath, t.TempDir(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Extensions) != 1 {
+		t.Fatalf("expected one extension, got %+v", cfg.Extensions)
+	}
+	if s := cfg.MCPServers["shared"]; s.Command != "user-shared" || s.Extension != "" {
+		t.Errorf("user server was taken over: %+v", s)
+	}
+	if s := cfg.MCPServers["own"]; s.Command != "ext-own" || s.Extension != "ext" {
+		t.Errorf("extension server not owned by the extension: %+v", s)
+	}
+}
@@ -0,0 +1,354 @@
+// Package diff parses and applies unified diffs, and produces them for previews.
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DevNull is the path used by unified diffs for a missing side
+const DevNull = "/dev/null"
+
+// MaxOffset is how many lines away from the line in its header a hunk may
+// apply. Like GNU patch's fuzz limit, it keeps a stale hunk from landing on
+// an unrelated copy of its context elsewhere in a large file.
+const MaxOffset = 1000
+
+// FilePatch is the part of a patch that touches one file
+type FilePatch struct {
+	OldPath string // "" when the file is created
+	NewPath string // "" when the file is deleted
+	Hunks   []Hunk
+}
+
+// IsNew reports whether the patch creates the file
+func (f *FilePatch) IsNew() bool { return f.OldPath == "" }
+
+// IsDelete reports whether the patch deletes the file
+func (f *FilePatch) IsDelete() bool { return f.NewPath == "" }
+
+// IsRename reports whether the patch moves the file
+func (f *FilePatch) IsRename() bool {
+	return f.OldPath != "" && f.NewPath != "" && f.OldPath != f.NewPath
+}
+
+// Path returns the path the patch applies to (the new path unless deleted)
+func (f *FilePatch) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Hunk is one @@ section of a file patch
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+	NoNewlineOld       bool // "\ No newline at end of file" after the old side
+	NoNewlineNew       bool // "\ No newline at end of file" after the new side
+}
+
+// Line is a hunk line: Op is ' ' (context), '-' (removed) or '+' (added)
+type Line struct {
+	Op   byte
+	Text string
+}
+
+// HunkResult describes how a hunk was applied
+type HunkResult struct {
+	Applied bool
+	Line    int  // 1-based line in the original file where the hunk matched
+	Offset  int  // distance from the line number in the hunk header
+	Fuzzy   bool // matched only after ignoring whitespace differences
+	Err     string
+}
+
+func (r HunkResult) String() string {
+	if !r.Applied {
+		return "failed: " + r.Err
+	}
+	s := fmt.Sprintf("applied at line %d", r.Line)
+	if r.Offset != 0 {
+		s += fmt.Sprintf(" (offset %+d)", r.Offset)
+	}
+	if r.Fuzzy {
+		s += " (ignoring whitespace)"
+	}
+	return s
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Parse reads a unified diff that may touch several files. Git extended
+// headers (new/deleted file mode, rename from/to) are understood.
+func Parse(patch string) ([]FilePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	var files []FilePatch
+	var cur *FilePatch
+	var hunk *Hunk
+
+	flush := func() {
+		if cur != nil {
+			files = append(files, *cur)
+		}
+		cur, hunk = nil, nil
+	}
+
+	oldLeft, newLeft := 0, 0 // lines still expected by the current hunk's header
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// Inside a hunk body, trust the header counts so that content like
+		// "--- x" is not mistaken for a file header
+		if hunk != nil && (oldLeft > 0 || newLeft > 0) {
+			op := byte(' ')
+			if line != "" {
+				op = line[0]
+			}
+			if op == ' ' || op == '-' || op == '+' {
+				text := ""
+				if line != "" {
+					text = line[1:]
+				}
+				hunk.Lines = append(hunk.Lines, Line{Op: op, Text: text})
+				if op != '+' {
+					oldLeft--
+				}
+				if op != '-' {
+					newLeft--
+				}
+				continue
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			cur = &FilePatch{}
+			if a, b, ok := parseGitHeader(strings.TrimPrefix(line, "diff --git ")); ok {
+				cur.OldPath, cur.NewPath = a, b
+			}
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if cur == nil || len(cur.Hunks) > 0 {
+				flush()
+				cur = &FilePatch{}
+			}
+			cur.OldPath = parsePath(strings.TrimPrefix(line, "--- "))
+			cur.NewPath = parsePath(strings.TrimPrefix(lines[i+1], "+++ "))
+			hunk = nil
+			i++
+
+		case cur != nil && hunk == nil && strings.HasPrefix(line, "new file mode"):
+			cur.OldPath = ""
+		case cur != nil && hunk == nil && strings.HasPrefix(line, "deleted file mode"):
+			cur.NewPath = ""
+		case cur != nil && hunk == nil && strings.HasPrefix(line, "rename from "):
+			cur.OldPath = strings.TrimPrefix(line, "rename from ")
+		case cur != nil && hunk == nil && strings.HasPrefix(line, "rename to "):
+			cur.NewPath = strings.TrimPrefix(line, "rename to ")
+
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk without a file header", i+1)
+			}
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: malformed hunk header %q", i+1, line)
+			}
+			cur.Hunks = append(cur.Hunks, Hunk{
+				OldStart: atoi(m[1]), OldLines: atoiDefault(m[2], 1),
+				NewStart: atoi(m[3]), NewLines: atoiDefault(m[4], 1),
+			})
+			hunk = &cur.Hunks[len(cur.Hunks)-1]
+			oldLeft, newLeft = hunk.OldLines, hunk.NewLines
+
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" refers to the preceding line
+			if n := len(hunk.Lines); n > 0 {
+				switch hunk.Lines[n-1].Op {
+				case '-':
+					hunk.NoNewlineOld = true
+				case '+':
+					hunk.NoNewlineNew = true
+				default:
+					hunk.NoNewlineOld, hunk.NoNewlineNew = true, true
+				}
+			}
+
+		case hunk != nil && line != "" && (line[0] == ' ' || line[0] == '-' || line[0] == '+'):
+			// Header counts were too small (common in hand-written patches)
+			hunk.Lines = append(hunk.Lines, Line{Op: line[0], Text: line[1:]})
+		}
+	}
+	flush()
+
+	var result []FilePatch
+	for _, f := range files {
+		if f.OldPath == "" && f.NewPath == "" {
+			continue
+		}
+		if len(f.Hunks) == 0 && !f.IsRename() && !f.IsDelete() && !f.IsNew() {
+			continue
+		}
+		result = append(result, f)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+	return result, nil
+}
+
+func parseGitHeader(s string) (string, string, bool) {
+	// "a/path b/path" — split at " b/" since paths may contain spaces
+	if i := strings.Index(s, " b/"); i >= 0 && strings.HasPrefix(s, "a/") {
+		return s[2:i], s[i+3:], true
+	}
+	return "", "", false
+}
+
+func parsePath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i] // strip timestamps
+	}
+	s = strings.TrimSpace(s)
+	if s == DevNull {
+		return ""
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		return s[2:]
+	}
+	return s
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	return atoi(s)
+}
+
+// Apply applies a file's hunks to content. Each hunk is located near the line
+// given in its header (searching outwards up to MaxOffset lines for an offset
+// match, then ignoring whitespace). If any hunk fails, an error is returned along with every
+// hunk's result and content is left untouched.
+func Apply(content string, hunks []Hunk) (string, []HunkResult, error) {
+	crlf := strings.Contains(content, "\r\n")
+	text := strings.ReplaceAll(content, "\r\n", "\n")
+	trailingNewline := text == "" || strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+
+	results := make([]HunkResult, len(hunks))
+	var out []string
+	pos := 0 // next unconsumed line of the original
+	failed := 0
+
+	for i, h := range hunks {
+		var oldBlock, newBlock []string
+		for _, l := range h.Lines {
+			if l.Op != '+' {
+				oldBlock = append(oldBlock, l.Text)
+			}
+			if l.Op != '-' {
+				newBlock = append(newBlock, l.Text)
+			}
+		}
+
+		// Header line numbers refer to the original file
+		expected := h.OldStart - 1
+		if len(oldBlock) == 0 {
+			expected = h.OldStart // "-N,0" inserts after line N
+		}
+
+		at, fuzzy := locate(lines, oldBlock, expected, pos)
+		if at < 0 {
+			results[i] = HunkResult{Err: fmt.Sprintf("hunk %d not found near line %d", i+1, h.OldStart)}
+			failed++
+			continue
+		}
+
+		results[i] = HunkResult{Applied: true, Line: at + 1, Offset: at - expected, Fuzzy: fuzzy}
+		out = append(out, lines[pos:at]...)
+		out = append(out, newBlock...)
+		pos = at + len(oldBlock)
+
+		if pos == len(lines) {
+			switch {
+			case h.NoNewlineNew:
+				trailingNewline = false
+			case h.NoNewlineOld || len(lines) == 0:
+				trailingNewline = true
+			}
+		}
+	}
+
+	if failed > 0 {
+		return content, results, fmt.Errorf("%d of %d hunks failed", failed, len(hunks))
+	}
+
+	out = append(out, lines[pos:]...)
+	result := strings.Join(out, "\n")
+	if trailingNewline && len(out) > 0 {
+		result += "\n"
+	}
+	if crlf {
+		result = strings.ReplaceAll(result, "\n", "\r\n")
+	}
+	return result, results, nil
+}
+
+// locate finds block in lines at or after min and within MaxOffset lines of
+// expected, preferring the closest position. It returns -1 if the block is
+// not found.
+func locate(lines, block []string, expected, min int) (int, bool) {
+	if len(block) == 0 {
+		if expected < min {
+			expected = min
+		}
+		if expected > len(lines) {
+			expected = len(lines)
+		}
+		return expected, false
+	}
+
+	for _, fuzzy := range []bool{false, true} {
+		for d := 0; d <= MaxOffset && d <= len(lines); d++ {
+			for _, at := range []int{expected - d, expected + d} {
+				if at < min || at+len(block) > len(lines) {
+					continue
+				}
+				if blockMatches(lines[at:at+len(block)], block, fuzzy) {
+					return at, fuzzy
+				}
+				if d == 0 {
+					break
+				}
+			}
+		}
+	}
+	return -1, false
+}
+
+func blockMatches(a, b []string, fuzzy bool) bool {
+	for i := range b {
+		if a[i] == b[i] {
+			continue
+		}
+		if !fuzzy || strings.Join(strings.Fields(a[i]), " ") != strings.Join(strings.Fields(b[i]), " ") {
+			return false
+		}
+	}
+	return true
+}
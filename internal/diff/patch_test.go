@@ -0,0 +1,123 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+const multiFilePatch = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,4 @@
+ package main
+
+-func old() {}
++func renamed() {}
+ // end
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/old/name.go b/new/name.go
+similarity index 100%
+rename from old/name.go
+rename to new/name.go
+`
+
+func TestParse(t *testing.T) {
+	files, err := Parse(multiFilePatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("expected 4 files, got %d: %+v", len(files), files)
+	}
+	if files[0].Path() != "main.go" || len(files[0].Hunks) != 1 || len(files[0].Hunks[0].Lines) != 5 {
+		t.Errorf("unexpected modify patch: %+v", files[0])
+	}
+	if !files[1].IsNew() || files[1].Path() != "new.txt" {
+		t.Errorf("expected creation of new.txt: %+v", files[1])
+	}
+	if !files[2].IsDelete() || files[2].Path() != "gone.txt" {
+		t.Errorf("expected deletion of gone.txt: %+v", files[2])
+	}
+	if !files[3].IsRename() || files[3].OldPath != "old/name.go" || files[3].NewPath != "new/name.go" {
+		t.Errorf("expected rename: %+v", files[3])
+	}
+}
+
+func TestParseTrustsHunkCounts(t *testing.T) {
+	patch := "--- a/q.sql\n+++ b/q.sql\n@@ -1,2 +1,2 @@\n--- comment\n+++ not a header\n select 1;\n"
+	files, err := Parse(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || len(files[0].Hunks[0].Lines) != 3 {
+		t.Fatalf("hunk body parsed as headers: %+v", files)
+	}
+}
+
+func TestApply(t *testing.T) {
+	content := "a\nb\nc\nd\ne\nf\ng\n"
+
+	// Header says line 2, but the text is at line 4: applied with an offset
+	hunks := []Hunk{{OldStart: 2, OldLines: 3, Lines: []Line{{' ', "c"}, {'-', "d"}, {'+', "D"}, {' ', "e"}}}}
+	got, results, err := Apply(content, hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "a\nb\nc\nD\ne\nf\ng\n" {
+		t.Errorf("unexpected result %q", got)
+	}
+	if results[0].Line != 3 || results[0].Offset != 1 {
+		t.Errorf("unexpected hunk result %+v", results[0])
+	}
+
+	// Whitespace differences are tolerated as a last resort
+	hunks = []Hunk{{OldStart: 1, Lines: []Line{{'-', "  a"}, {'+', "A"}}}}
+	got, results, err = Apply(content, hunks)
+	if err != nil || !strings.HasPrefix(got, "A\nb") || !results[0].Fuzzy {
+		t.Errorf("fuzzy apply: %q %+v %v", got, results, err)
+	}
+
+	// A failing hunk leaves the content untouched and reports every hunk
+	hunks = []Hunk{
+		{OldStart: 1, Lines: []Line{{'-', "a"}, {'+', "A"}}},
+		{OldStart: 5, Lines: []Line{{'-', "missing"}}},
+	}
+	got, results, err = Apply(content, hunks)
+	if err == nil || got != content || !results[0].Applied || results[1].Applied {
+		t.Errorf("expected atomic failure, got %q %+v %v", got, results, err)
+	}
+	if results[1].Err != "hunk 2 not found near line 5" {
+		t.Errorf("unexpected hunk error %q", results[1].Err)
+	}
+
+	// Context further than MaxOffset lines from the header line is not searched
+	far := strings.Repeat("x\n", MaxOffset+10) + "target\n"
+	hunks = []Hunk{{OldStart: 1, Lines: []Line{{'-', "target"}, {'+', "T"}}}}
+	if _, results, err = Apply(far, hunks); err == nil || results[0].Applied {
+		t.Errorf("hunk applied %d lines away: %+v", MaxOffset+10, results)
+	}
+}
+
+func TestApplyNewFileAndNewline(t *testing.T) {
+	got, _, err := Apply("", []Hunk{{OldStart: 0, OldLines: 0, Lines: []Line{{'+', "x"}, {'+', "y"}}}})
+	if err != nil || got != "x\ny\n" {
+		t.Errorf("new file: %q %v", got, err)
+	}
+
+	got, _, err = Apply("x\r\ny\r\n", []Hunk{{OldStart: 2, Lines: []Line{{'-', "y"}, {'+', "z"}}, NoNewlineNew: true}})
+	if err != nil || got != "x\r\nz" {
+		t.Errorf("crlf without trailing newline: %q %v", got, err)
+	}
+}
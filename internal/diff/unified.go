@@ -0,0 +1,256 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContextLines is the number of unchanged lines shown around each change
+const ContextLines = 3
+
+// maxEditDistance bounds the diff search; beyond it the differing region is
+// shown as a single replacement
+const maxEditDistance = 2000
+
+// noEOL marks a last line without a trailing newline so that it never equals
+// the same text with one
+const noEOL = "\x00"
+
+// Unified returns a unified diff between oldText and newText along with the
+// number of added and removed lines. An empty name is written as /dev/null.
+// The diff is empty when the texts are equal.
+func Unified(oldName, newName, oldText, newText string) (string, int, int) {
+	a, b := splitLines(oldText), splitLines(newText)
+	ops := diffLines(a, b)
+
+	added, removed := 0, 0
+	for _, op := range ops {
+		switch op.Op {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	if added == 0 && removed == 0 {
+		return "", 0, 0
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- " + diffName("a/", oldName) + "\n")
+	sb.WriteString("+++ " + diffName("b/", newName) + "\n")
+	for _, h := range groupHunks(ops) {
+		writeHunk(&sb, ops[h.start:h.end], h.oldStart, h.newStart)
+	}
+	return sb.String(), added, removed
+}
+
+func diffName(prefix, name string) string {
+	if name == "" {
+		return DevNull
+	}
+	return prefix + name
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	eol := strings.HasSuffix(s, "\n")
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if !eol {
+		lines[len(lines)-1] += noEOL
+	}
+	return lines
+}
+
+// diffLines returns the edit script turning a into b
+func diffLines(a, b []string) []Line {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	ops := make([]Line, 0, len(a)+len(b))
+	for _, l := range a[:pre] {
+		ops = append(ops, Line{' ', l})
+	}
+	ops = append(ops, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, Line{' ', l})
+	}
+	return ops
+}
+
+// myers implements the greedy O(ND) algorithm, keeping only the part of
+// each round's frontier needed for backtracking
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		if d > maxEditDistance {
+			return replaceAll(a, b)
+		}
+		// Frontier before this round for k in [-d-1, d+1]
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return replaceAll(a, b)
+}
+
+func backtrack(a, b []string, trace [][]int) []Line {
+	var rev []Line
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		snap := trace[d]
+		at := func(k int) int { return snap[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			rev = append(rev, Line{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				rev = append(rev, Line{'+', b[y-1]})
+			} else {
+				rev = append(rev, Line{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	ops := make([]Line, len(rev))
+	for i, op := range rev {
+		ops[len(rev)-1-i] = op
+	}
+	return ops
+}
+
+func replaceAll(a, b []string) []Line {
+	ops := make([]Line, 0, len(a)+len(b))
+	for _, l := range a {
+		ops = append(ops, Line{'-', l})
+	}
+	for _, l := range b {
+		ops = append(ops, Line{'+', l})
+	}
+	return ops
+}
+
+type hunkRange struct {
+	start, end         int // range in the edit script
+	oldStart, newStart int // 0-based line numbers of the first line
+}
+
+// groupHunks splits the edit script into hunks, merging changes that are
+// separated by no more than twice the context
+func groupHunks(ops []Line) []hunkRange {
+	var hunks []hunkRange
+	oldLine, newLine := 0, 0
+	var cur *hunkRange
+	lastChange := -1
+
+	for i, op := range ops {
+		if op.Op != ' ' {
+			if cur == nil || i-lastChange > 2*ContextLines {
+				if cur != nil {
+					cur.end = lastChange + 1 + ContextLines
+				}
+				start := i - ContextLines
+				if start < 0 {
+					start = 0
+				}
+				// Line numbers at start: walk back over the context lines
+				hunks = append(hunks, hunkRange{start: start, oldStart: oldLine - (i - start), newStart: newLine - (i - start)})
+				cur = &hunks[len(hunks)-1]
+			}
+			lastChange = i
+		}
+		if op.Op != '+' {
+			oldLine++
+		}
+		if op.Op != '-' {
+			newLine++
+		}
+	}
+	if cur != nil {
+		cur.end = lastChange + 1 + ContextLines
+		if cur.end > len(ops) {
+			cur.end = len(ops)
+		}
+	}
+	return hunks
+}
+
+func writeHunk(sb *strings.Builder, ops []Line, oldStart, newStart int) {
+	oldCount, newCount := 0, 0
+	for _, op := range ops {
+		if op.Op != '+' {
+			oldCount++
+		}
+		if op.Op != '-' {
+			newCount++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRangeString(oldStart, oldCount), hunkRangeString(newStart, newCount))
+	for _, op := range ops {
+		text, missingEOL := strings.CutSuffix(op.Text, noEOL)
+		sb.WriteByte(op.Op)
+		sb.WriteString(text)
+		sb.WriteByte('\n')
+		if missingEOL {
+			sb.WriteString("\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRangeString(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+
+	got, added, removed := Unified("f.txt", "f.txt", old, new)
+	want := `--- a/f.txt
++++ b/f.txt
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -10,3 +10,4 @@
+ j
+ k
+ l
++m
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if added != 2 || removed != 1 {
+		t.Errorf("counts: +%d -%d", added, removed)
+	}
+
+	if d, _, _ := Unified("f", "f", old, old); d != "" {
+		t.Errorf("expected empty diff for equal texts, got %q", d)
+	}
+}
+
+func TestUnifiedRoundTrip(t *testing.T) {
+	tests := []struct{ old, new string }{
+		{"", "hello\nworld\n"},
+		{"bye\n", ""},
+		{"x\ny", "x\ny\n"},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "0\n1\n3\n4\n5\n6\n7\nseven\n8\n10\n11\n"},
+	}
+	for _, tt := range tests {
+		d, _, _ := Unified("f", "f", tt.old, tt.new)
+		if tt.old == "" {
+			d = strings.Replace(d, "--- a/f", "--- "+DevNull, 1)
+		}
+		files, err := Parse(d)
+		if err != nil {
+			t.Fatalf("%q -> %q: %v\n%s", tt.old, tt.new, err, d)
+		}
+		got, _, err := Apply(tt.old, files[0].Hunks)
+		if err != nil || got != tt.new {
+			t.Errorf("%q -> %q: round trip gave %q (%v)\n%s", tt.old, tt.new, got, err, d)
+		}
+	}
+}
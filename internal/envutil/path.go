@@ -6,6 +6,8 @@ package envutil
 
 import (
 	"os"
+	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
 )
@@ -54,3 +56,28 @@ func ShellEnv() []string {
 
 	return env
 }
+
+// LookPath searches for an executable in the PATH returned by ShellEnv
+func LookPath(name string) (string, error) {
+	for _, e := range ShellEnv() {
+		if !strings.HasPrefix(e, "PATH=") {
+			continue
+		}
+		for _, dir := range filepath.SplitList(e[5:]) {
+			if dir == "" {
+				continue
+			}
+			candidates := []string{filepath.Join(dir, name)}
+			if runtime.GOOS == "windows" {
+				candidates = []string{filepath.Join(dir, name+".exe"), filepath.Join(dir, name)}
+			}
+			for _, p := range candidates {
+				if info, err := os.Stat(p); err == nil && !info.IsDir() && (runtime.GOOS == "windows" || info.Mode()&0o111 != 0) {
+					return p, nil
+				}
+			}
+		}
+		break
+	}
+	return exec.LookPath(name)
+}
@@ -0,0 +1,282 @@
+// Package glob implements doublestar glob matching for the file tools.
+// Supported syntax: "*" (within a path segment), "**" (any number of
+// segments), "?", character classes ("[a-z]", "[!0-9]") and brace
+// alternatives ("{a,b}", which may nest). Paths use forward slashes.
+package glob
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RecentThreshold is how recently a file must have changed to be listed
+// first by SortByModTime (same as Gemini CLI's glob tool)
+const RecentThreshold = 24 * time.Hour
+
+// Options controls a Glob walk
+type Options struct {
+	// Skip is called for every directory and file under the walk root;
+	// returning true skips the file or the whole directory.
+	Skip func(path string, isDir bool) bool
+	// Limit stops the walk after this many matches (0 = no limit).
+	Limit int
+}
+
+// Compile translates a glob pattern into a regular expression matching whole paths
+func Compile(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^" + Translate(filepath.ToSlash(pattern), true) + "$")
+}
+
+// Match reports whether a slash-separated path matches pattern.
+// Malformed patterns never match.
+func Match(pattern, path string) bool {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(filepath.ToSlash(path))
+}
+
+// HasMeta reports whether s contains glob syntax
+func HasMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[{")
+}
+
+// SplitPattern splits a pattern into its static directory prefix and the
+// remaining pattern, e.g. "src/**/*.ts" -> ("src", "**/*.ts").
+func SplitPattern(pattern string) (prefix, rest string) {
+	pattern = filepath.ToSlash(pattern)
+	segments := strings.Split(pattern, "/")
+	for i, seg := range segments {
+		if HasMeta(seg) {
+			return strings.Join(segments[:i], "/"), strings.Join(segments[i:], "/")
+		}
+	}
+	// No glob syntax: the last segment is the file name
+	i := strings.LastIndex(pattern, "/")
+	if i < 0 {
+		return "", pattern
+	}
+	return pattern[:i], pattern[i+1:]
+}
+
+// Base returns the directory Glob walks for pattern: its static prefix, joined
+// to root unless the pattern is absolute.
+func Base(root, pattern string) string {
+	prefix, _ := SplitPattern(pattern)
+	base := filepath.FromSlash(prefix)
+	switch {
+	case filepath.IsAbs(pattern) && prefix == "":
+		return string(filepath.Separator)
+	case !filepath.IsAbs(pattern):
+		return filepath.Join(root, base)
+	}
+	return base
+}
+
+// Glob returns the absolute paths of files under root matching pattern.
+// Absolute patterns are matched as-is. The walk starts at the pattern's static
+// prefix (see Base), and only descends as deep as the pattern can match.
+func Glob(root, pattern string, opts Options) ([]string, error) {
+	_, rest := SplitPattern(pattern)
+	base := Base(root, pattern)
+
+	re, err := Compile(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	maxDepth := -1 // unlimited
+	if !strings.Contains(rest, "**") {
+		maxDepth = strings.Count(rest, "/")
+	}
+
+	var matches []string
+	err = filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == base {
+				return err
+			}
+			return nil // Skip unreadable entries
+		}
+		if path == base {
+			return nil
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if opts.Skip != nil && opts.Skip(path, true) {
+				return filepath.SkipDir
+			}
+			if maxDepth >= 0 && strings.Count(rel, "/") >= maxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if opts.Skip != nil && opts.Skip(path, false) {
+			return nil
+		}
+		if re.MatchString(rel) {
+			matches = append(matches, path)
+			if opts.Limit > 0 && len(matches) >= opts.Limit {
+				return filepath.SkipAll
+			}
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return matches, err
+	}
+	return matches, nil
+}
+
+// SortByModTime orders paths the way Gemini CLI's glob does: files modified
+// within RecentThreshold first (newest first), then the rest alphabetically.
+func SortByModTime(paths []string) {
+	now := time.Now()
+	modTimes := make(map[string]time.Time, len(paths))
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil {
+			modTimes[p] = info.ModTime()
+		}
+	}
+	isRecent := func(p string) bool {
+		return now.Sub(modTimes[p]) < RecentThreshold
+	}
+
+	sort.SliceStable(paths, func(i, j int) bool {
+		a, b := paths[i], paths[j]
+		recentA, recentB := isRecent(a), isRecent(b)
+		switch {
+		case recentA && recentB:
+			return modTimes[a].After(modTimes[b])
+		case recentA != recentB:
+			return recentA
+		default:
+			return a < b
+		}
+	})
+}
+
+// Translate converts glob syntax into a regular expression fragment. It is
+// shared with the .gitignore matcher, which passes braces=false because
+// gitignore treats "{" as a literal character.
+func Translate(pattern string, braces bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**") {
+				atStart := i == 0 || pattern[i-1] == '/'
+				rest := pattern[i+2:]
+				switch {
+				case atStart && strings.HasPrefix(rest, "/"):
+					// "**/" matches zero or more directories
+					sb.WriteString("(?:[^/]*/)*")
+					i += 2
+				case atStart && rest == "":
+					// trailing "**" matches everything below
+					sb.WriteString(".*")
+					i++
+				default:
+					sb.WriteString("[^/]*")
+					i++
+				}
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '{':
+			end := -1
+			if braces {
+				end = matchingBrace(pattern, i)
+			}
+			if end < 0 {
+				sb.WriteString(`\{`)
+				continue
+			}
+			alts := splitAlternatives(pattern[i+1 : end])
+			sb.WriteString("(?:")
+			for j, alt := range alts {
+				if j > 0 {
+					sb.WriteString("|")
+				}
+				sb.WriteString(Translate(alt, true))
+			}
+			sb.WriteString(")")
+			i = end
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// matchingBrace returns the index of the '}' closing the '{' at start, or -1
+func matchingBrace(pattern string, start int) int {
+	depth := 0
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitAlternatives splits brace contents on top-level commas
+func splitAlternatives(s string) []string {
+	var alts []string
+	depth, last := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(alts, s[last:])
+}
@@ -0,0 +1,140 @@
+package glob
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/app/main.go", true},
+		{"src/**/*.ts", "src/index.ts", true},
+		{"src/**/*.ts", "src/a/b/index.ts", true},
+		{"src/**/*.ts", "lib/index.ts", false},
+		{"src/**/*.ts", "src/index.tsx", false},
+		{"src/**", "src/a/b.txt", true},
+		{"*.{ts,tsx}", "app.tsx", true},
+		{"*.{ts,tsx}", "app.js", false},
+		{"{src,lib}/**/*.{js,ts}", "lib/x/y.js", true},
+		{"{a,b{c,d}}.txt", "bd.txt", true},
+		{"file[0-9].txt", "file7.txt", true},
+		{"file[!0-9].txt", "file7.txt", false},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file/.txt", false},
+		{`\*.txt`, "*.txt", true},
+		{`\*.txt`, "a.txt", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "ax/b", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSplitPattern(t *testing.T) {
+	tests := []struct{ pattern, prefix, rest string }{
+		{"src/**/*.ts", "src", "**/*.ts"},
+		{"*.go", "", "*.go"},
+		{"a/b/c.go", "a/b", "c.go"},
+		{"a/{b,c}/*.go", "a", "{b,c}/*.go"},
+	}
+	for _, tt := range tests {
+		prefix, rest := SplitPattern(tt.pattern)
+		if prefix != tt.prefix || rest != tt.rest {
+			t.Errorf("SplitPattern(%q) = (%q, %q), want (%q, %q)", tt.pattern, prefix, rest, tt.prefix, tt.rest)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"main.go", "cmd/app/main.go", "src/a.ts", "src/sub/b.ts", "src/sub/c.tsx", "skip/x.ts"} {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		os.WriteFile(p, nil, 0o644)
+	}
+
+	rel := func(paths []string) []string {
+		var out []string
+		for _, p := range paths {
+			r, _ := filepath.Rel(root, p)
+			out = append(out, filepath.ToSlash(r))
+		}
+		return out
+	}
+
+	got, err := Glob(root, "src/**/*.ts", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"src/a.ts", "src/sub/b.ts"}; !reflect.DeepEqual(rel(got), want) {
+		t.Errorf("got %v, want %v", rel(got), want)
+	}
+
+	got, _ = Glob(root, "*.go", Options{})
+	if want := []string{"main.go"}; !reflect.DeepEqual(rel(got), want) {
+		t.Errorf("non-recursive pattern: got %v, want %v", rel(got), want)
+	}
+
+	skip := func(path string, isDir bool) bool { return isDir && filepath.Base(path) == "skip" }
+	got, _ = Glob(root, "**/*.{ts,tsx}", Options{Skip: skip})
+	for _, p := range rel(got) {
+		if strings.HasPrefix(p, "skip/") {
+			t.Errorf("skipped directory was walked: %s", p)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("expected 3 matches, got %v", rel(got))
+	}
+
+	got, _ = Glob(root, filepath.ToSlash(filepath.Join(root, "cmd", "**", "*.go")), Options{})
+	if want := []string{"cmd/app/main.go"}; !reflect.DeepEqual(rel(got), want) {
+		t.Errorf("absolute pattern: got %v, want %v", rel(got), want)
+	}
+
+	if got, err := Glob(root, "missing/**/*.go", Options{}); err != nil || len(got) != 0 {
+		t.Errorf("missing prefix: got %v, %v", got, err)
+	}
+}
+
+func TestSortByModTime(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := map[string]time.Time{
+		"old-b.txt":   now.Add(-72 * time.Hour),
+		"old-a.txt":   now.Add(-48 * time.Hour),
+		"recent1.txt": now.Add(-2 * time.Hour),
+		"recent2.txt": now.Add(-1 * time.Hour),
+	}
+	var paths []string
+	for name, mtime := range files {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, nil, 0o644)
+		os.Chtimes(p, mtime, mtime)
+		paths = append(paths, p)
+	}
+
+	SortByModTime(paths)
+	var names []string
+	for _, p := range paths {
+		names = append(names, filepath.Base(p))
+	}
+	want := []string{"recent2.txt", "recent1.txt", "old-a.txt", "old-b.txt"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
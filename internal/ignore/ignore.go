@@ -0,0 +1,248 @@
+// Package ignore implements .gitignore matching for the file tools.
+// Patterns are read from .gitignore and .geminiignore files in every directory
+// (nested files apply to their own subtree) and from .git/info/exclude, with
+// gitignore semantics: negation, anchored and directory-only patterns, and **.
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/glob"
+)
+
+// IgnoreFiles are the per-directory pattern files, in increasing precedence
+var IgnoreFiles = []string{".gitignore", ".geminiignore"}
+
+// defaultPatterns are always applied with the lowest precedence
+var defaultPatterns = []string{".git/", "node_modules/"}
+
+// rule is a single compiled pattern line
+type rule struct {
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	basename bool // pattern has no slash: match the last path component at any depth
+}
+
+func (r *rule) match(rel, name string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.basename {
+		return r.re.MatchString(name)
+	}
+	return r.re.MatchString(rel)
+}
+
+// Matcher answers whether paths below a root are ignored. It loads pattern
+// files lazily and caches them, so it is cheap to reuse during a walk.
+type Matcher struct {
+	root string // root passed to New; relative paths resolve against it
+	base string // directory that owns the top-level patterns (the git root when found)
+
+	mu       sync.Mutex
+	rules    map[string][]rule // directory (relative to base, "" for base) -> its rules
+	dirCache map[string]bool   // relative dir path -> ignored
+}
+
+// New creates a matcher for paths below root. When root is inside a git
+// repository, .gitignore files between the repository root and root apply too.
+func New(root string) *Matcher {
+	root = filepath.Clean(root)
+	base := root
+	for dir := root; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			base = dir
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	m := &Matcher{
+		root:     root,
+		base:     base,
+		rules:    make(map[string][]rule),
+		dirCache: make(map[string]bool),
+	}
+
+	top := compile(defaultPatterns)
+	if lines, err := readLines(filepath.Join(base, ".git", "info", "exclude")); err == nil {
+		top = append(top, compile(lines)...)
+	}
+	top = append(top, m.loadDir(base)...)
+	m.rules[""] = top
+	return m
+}
+
+// Match reports whether path (absolute, or relative to the root passed to New)
+// is ignored, either directly or because one of its parent directories is.
+func (m *Matcher) Match(path string, isDir bool) bool {
+	rel, ok := m.relative(path)
+	if !ok || rel == "" {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.dirIgnored(strings.Join(parts[:i], "/")) {
+			return true
+		}
+	}
+	if isDir {
+		return m.dirIgnored(rel)
+	}
+	return m.ignored(rel, false)
+}
+
+func (m *Matcher) relative(path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.root, path)
+	}
+	rel, err := filepath.Rel(m.base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		return "", true
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func (m *Matcher) dirIgnored(rel string) bool {
+	if v, ok := m.dirCache[rel]; ok {
+		return v
+	}
+	v := m.ignored(rel, true)
+	m.dirCache[rel] = v
+	return v
+}
+
+// ignored applies the rules of every directory from base down to rel's parent.
+// Deeper files take precedence, and within a file the last matching line wins.
+func (m *Matcher) ignored(rel string, isDir bool) bool {
+	name := rel[strings.LastIndex(rel, "/")+1:]
+	ignored := false
+
+	dir := ""
+	for {
+		sub := rel
+		if dir != "" {
+			sub = strings.TrimPrefix(rel, dir+"/")
+		}
+		for _, r := range m.rulesFor(dir) {
+			if r.match(sub, name, isDir) {
+				ignored = !r.negate
+			}
+		}
+
+		next := strings.IndexByte(sub, '/')
+		if next < 0 {
+			break
+		}
+		if dir == "" {
+			dir = sub[:next]
+		} else {
+			dir = dir + "/" + sub[:next]
+		}
+	}
+	return ignored
+}
+
+func (m *Matcher) rulesFor(dir string) []rule {
+	if r, ok := m.rules[dir]; ok {
+		return r
+	}
+	r := m.loadDir(filepath.Join(m.base, filepath.FromSlash(dir)))
+	m.rules[dir] = r
+	return r
+}
+
+func (m *Matcher) loadDir(dir string) []rule {
+	var rules []rule
+	for _, name := range IgnoreFiles {
+		if lines, err := readLines(filepath.Join(dir, name)); err == nil {
+			rules = append(rules, compile(lines)...)
+		}
+	}
+	return rules
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+func compile(lines []string) []rule {
+	var rules []rule
+	for _, line := range lines {
+		if r, ok := parseLine(line); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// parseLine compiles one gitignore line; blank lines and comments yield false
+func parseLine(line string) (rule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	// A slash at the start or in the middle anchors the pattern to its directory
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		r.basename = true
+	}
+
+	// gitignore has no brace alternatives; "{" is a literal character
+	re, err := regexp.Compile("^" + glob.Translate(line, false) + "$")
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
@@ -0,0 +1,95 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, ".git", "info"), 0o755)
+	writeFile(t, filepath.Join(root, ".git", "info", "exclude"), "secret.txt\n")
+	writeFile(t, filepath.Join(root, ".gitignore"), `# comment
+*.log
+!keep.log
+/build
+dist/
+docs/**/*.tmp
+generated/**
+\#hash
+{a,b}.txt
+`)
+	writeFile(t, filepath.Join(root, "pkg", ".gitignore"), "local.txt\n/anchored.txt\n!*.log\n")
+	writeFile(t, filepath.Join(root, ".geminiignore"), "private/\n")
+
+	m := New(root)
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"main.go", false, false},
+		{"app.log", false, true},
+		{"deep/nested/app.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build/out.bin", false, true},
+		{"src/build", true, false}, // anchored to the root
+		{"dist", false, false},     // directory-only pattern
+		{"dist", true, true},
+		{"src/dist/x.js", false, true},
+		{"docs/a/b/c.tmp", false, true},
+		{"docs/c.tmp", false, true},
+		{"generated/x/y.go", false, true},
+		{"#hash", false, true},
+		{"{a,b}.txt", false, true}, // no brace alternatives in gitignore
+		{"a.txt", false, false},
+		{"secret.txt", false, true},
+		{"private/key", false, true},
+		{"node_modules/lib/index.js", false, true},
+		{".git/config", false, true},
+		{"pkg/local.txt", false, true},
+		{"pkg/sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{"pkg/anchored.txt", false, true},
+		{"pkg/sub/anchored.txt", false, false},
+		{"pkg/debug.log", false, false}, // re-included by the nested file
+	}
+	for _, tt := range tests {
+		if got := m.Match(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("Match(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.ignored)
+		}
+	}
+
+	if !m.Match(filepath.Join(root, "app.log"), false) {
+		t.Error("absolute path not matched")
+	}
+	if m.Match(filepath.Join(filepath.Dir(root), "app.log"), false) {
+		t.Error("path outside the root must not be ignored")
+	}
+}
+
+func TestMatchUsesRepositoryRoot(t *testing.T) {
+	repo := t.TempDir()
+	os.Mkdir(filepath.Join(repo, ".git"), 0o755)
+	writeFile(t, filepath.Join(repo, ".gitignore"), "*.gen.go\n/sub/tmp/\n")
+
+	m := New(filepath.Join(repo, "sub"))
+	if !m.Match("types.gen.go", false) {
+		t.Error("pattern from the repository root not applied to a subdirectory root")
+	}
+	if !m.Match("tmp/cache", false) {
+		t.Error("anchored pattern from the repository root not applied")
+	}
+}
@@ -0,0 +1,242 @@
+// Package sandbox runs shell commands inside a restricted environment: a
+// Linux namespace (bubblewrap or unshare) or a Docker/Podman container. The
+// working directory is writable, the rest of the filesystem is read-only, the
+// network can be disabled and only a small set of environment variables is
+// passed through.
+package sandbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Profiles
+const (
+	Auto    = "auto" // first available of bwrap and unshare
+	Bwrap   = "bwrap"
+	Unshare = "unshare"
+	Docker  = "docker"
+	Podman  = "podman"
+)
+
+// DefaultImage is the container image used by the docker and podman profiles
+const DefaultImage = "ubuntu:24.04"
+
+// keepEnv lists the environment variables passed into every sandbox
+var keepEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_ALL", "LC_CTYPE", "TERM", "TZ", "TMPDIR"}
+
+// Options describe one sandboxed command
+type Options struct {
+	Profile       string
+	Dir           string   // directory the command runs in
+	WritablePaths []string // mounted read-write (the workspace roots)
+	Network       bool     // allow network access
+	Image         string   // container image for docker/podman
+	Env           []string // extra environment variable names to pass through
+}
+
+// Command is a sandboxed command line. Cleanup releases resources the
+// sandbox may leave behind when the command is killed (e.g. containers).
+type Command struct {
+	Args    []string
+	Env     []string
+	Cleanup func()
+}
+
+// Wrap returns the command line that runs argv inside the sandbox, with env
+// reduced to the variables the sandbox passes through
+func Wrap(opts Options, argv, env []string) (*Command, error) {
+	profile := opts.Profile
+	if profile == "" || profile == Auto {
+		p, err := Detect()
+		if err != nil {
+			return nil, err
+		}
+		profile = p
+	}
+
+	c := &Command{Env: scrubEnv(env, opts.Env), Cleanup: func() {}}
+	switch profile {
+	case Bwrap:
+		if err := requireLinux(profile); err != nil {
+			return nil, err
+		}
+		c.Args = bwrapArgs(opts, argv)
+	case Unshare:
+		if err := requireLinux(profile); err != nil {
+			return nil, err
+		}
+		c.Args = unshareArgs(opts, argv)
+	case Docker, Podman:
+		name := "gmn-sandbox-" + randomID()
+		c.Args = containerArgs(profile, name, opts, argv, c.Env)
+		c.Cleanup = func() {
+			exec.Command(profile, "rm", "-f", name).Run()
+		}
+	default:
+		return nil, fmt.Errorf("unknown sandbox profile %q (use bwrap, unshare, docker or podman)", profile)
+	}
+
+	if _, err := exec.LookPath(c.Args[0]); err != nil {
+		return nil, fmt.Errorf("sandbox profile %q: %s not found", profile, c.Args[0])
+	}
+	return c, nil
+}
+
+// Detect returns the first namespace tool available on this system
+func Detect() (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("namespace sandboxes are only available on Linux; use the docker or podman profile")
+	}
+	for _, p := range []string{Bwrap, Unshare} {
+		if _, err := exec.LookPath(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no sandbox available: install bubblewrap (bwrap) or util-linux (unshare)")
+}
+
+func requireLinux(profile string) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("sandbox profile %q is only available on Linux", profile)
+	}
+	return nil
+}
+
+func bwrapArgs(opts Options, argv []string) []string {
+	args := []string{"bwrap",
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+	}
+	for _, p := range opts.WritablePaths {
+		args = append(args, "--bind", p, p)
+	}
+	if !opts.Network {
+		args = append(args, "--unshare-net")
+	}
+	args = append(args, "--unshare-pid", "--die-with-parent")
+	if opts.Dir != "" {
+		args = append(args, "--chdir", opts.Dir)
+	}
+	return append(append(args, "--"), argv...)
+}
+
+// unshareArgs builds an unprivileged user+mount namespace and, inside it, a
+// read-only copy of the root filesystem with a private /tmp and the writable
+// paths bound read-write, then chroots into it
+func unshareArgs(opts Options, argv []string) []string {
+	var script strings.Builder
+	script.WriteString("set -e\n")
+	if stage := stagingDir(append([]string{opts.Dir}, opts.WritablePaths...)); stage != "" {
+		// Build the root on a tmpfs that only exists in this namespace
+		fmt.Fprintf(&script, "mount -t tmpfs tmpfs %s\nR=%s/root\nmkdir \"$R\"\n", stage, stage)
+	} else {
+		script.WriteString("R=$(mktemp -d)\n")
+	}
+	script.WriteString(`mount --rbind / "$R"` + "\n")
+	script.WriteString(`mount --make-rprivate "$R"` + "\n")
+	script.WriteString(`awk -v r="$R" '$5 == r || index($5, r "/") == 1 {print $5}' /proc/self/mountinfo | sort -u | while read -r m; do ` +
+		`case "$m" in "$R"/proc*|"$R"/dev*|"$R"/sys*) continue;; esac; ` +
+		`mount -o remount,bind,ro "$m" 2>/dev/null || true; done` + "\n")
+	script.WriteString(`mount -t tmpfs tmpfs "$R/tmp"` + "\n")
+	script.WriteString(`mount -t proc proc "$R/proc" 2>/dev/null || true` + "\n")
+	for _, p := range opts.WritablePaths {
+		q := shellQuote(p)
+		fmt.Fprintf(&script, `mkdir -p "$R"%s 2>/dev/null || true; mount --bind %s "$R"%s`+"\n", q, q, q)
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = "/"
+	}
+	fmt.Fprintf(&script, `exec chroot "$R" sh -c 'cd "$0" && exec "$@"' %s "$@"`, shellQuote(dir))
+
+	args := []string{"unshare", "--user", "--map-root-user", "--mount", "--pid", "--fork", "--kill-child"}
+	if !opts.Network {
+		args = append(args, "--net")
+	}
+	args = append(args, "sh", "-c", script.String(), "sandbox")
+	return append(args, argv...)
+}
+
+// stagingDir returns an existing directory that does not contain any of
+// paths, to be hidden under a tmpfs while the sandbox root is assembled
+func stagingDir(paths []string) string {
+	for _, d := range []string{"/mnt", "/media", "/srv", "/opt", "/run"} {
+		if info, err := os.Stat(d); err != nil || !info.IsDir() {
+			continue
+		}
+		free := true
+		for _, p := range paths {
+			if p == d || strings.HasPrefix(p, d+"/") {
+				free = false
+			}
+		}
+		if free {
+			return d
+		}
+	}
+	return ""
+}
+
+func containerArgs(engine, name string, opts Options, argv, env []string) []string {
+	image := opts.Image
+	if image == "" {
+		image = DefaultImage
+	}
+	args := []string{engine, "run", "--rm", "-i", "--init", "--name", name, "--read-only", "--tmpfs", "/tmp"}
+	if !opts.Network {
+		args = append(args, "--network", "none")
+	}
+	if runtime.GOOS == "linux" {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	for _, p := range opts.WritablePaths {
+		args = append(args, "-v", p+":"+p)
+	}
+	if opts.Dir != "" {
+		args = append(args, "-w", opts.Dir)
+	}
+	// The image has its own PATH and HOME
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if name != "PATH" && name != "HOME" && name != "SHELL" && name != "TMPDIR" {
+			args = append(args, "-e", name)
+		}
+	}
+	return append(append(args, image), argv...)
+}
+
+// scrubEnv keeps only the standard variables and the extra names in env
+func scrubEnv(env, extra []string) []string {
+	keep := make(map[string]bool)
+	for _, k := range keepEnv {
+		keep[k] = true
+	}
+	for _, k := range extra {
+		keep[k] = true
+	}
+	var out []string
+	for _, kv := range env {
+		if name, _, ok := strings.Cut(kv, "="); ok && keep[name] {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func randomID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,73 @@
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWrapArgs(t *testing.T) {
+	opts := Options{Dir: "/work", WritablePaths: []string{"/work"}}
+
+	args := bwrapArgs(opts, []string{"bash", "-c", "true"})
+	if !slices.Contains(args, "--unshare-net") || strings.Join(args[len(args)-4:], " ") != "-- bash -c true" {
+		t.Errorf("unexpected bwrap args: %v", args)
+	}
+	opts.Network = true
+	if args := bwrapArgs(opts, nil); slices.Contains(args, "--unshare-net") {
+		t.Errorf("network should be allowed: %v", args)
+	}
+
+	args = containerArgs(Docker, "box", Options{Dir: "/work", WritablePaths: []string{"/work"}}, []string{"ls"}, []string{"PATH=/bin", "LANG=C"})
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "--network none") || !strings.Contains(joined, "-v /work:/work") ||
+		!strings.Contains(joined, "-e LANG") || strings.Contains(joined, "-e PATH") || !strings.HasSuffix(joined, DefaultImage+" ls") {
+		t.Errorf("unexpected docker args: %s", joined)
+	}
+
+	env := scrubEnv([]string{"PATH=/bin", "AWS_SECRET_ACCESS_KEY=x", "GOPATH=/go"}, []string{"GOPATH"})
+	if strings.Join(env, " ") != "PATH=/bin GOPATH=/go" {
+		t.Errorf("unexpected environment: %v", env)
+	}
+}
+
+func TestUnshareIsolation(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux only")
+	}
+	if _, err := exec.LookPath("unshare"); err != nil {
+		t.Skip("unshare not installed")
+	}
+	if err := exec.Command("unshare", "--user", "--map-root-user", "--mount", "true").Run(); err != nil {
+		t.Skip("user namespaces not available")
+	}
+
+	work := t.TempDir()
+	outside := t.TempDir()
+	c, err := Wrap(Options{Profile: Unshare, Dir: work, WritablePaths: []string{work}},
+		[]string{"sh", "-c", "echo ok > inside.txt; echo no > " + filepath.Join(outside, "f.txt") + "; echo $SECRET; pwd; touch /usr/sandbox-test 2>/dev/null && echo root-writable"},
+		append(os.Environ(), "SECRET=leaked"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(c.Args[0], c.Args[1:]...)
+	cmd.Env = c.Env
+	out, _ := cmd.CombinedOutput()
+
+	if data, _ := os.ReadFile(filepath.Join(work, "inside.txt")); string(data) != "ok\n" {
+		t.Errorf("work directory not writable: %s", out)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "f.txt")); err == nil {
+		t.Errorf("wrote outside the work directory: %s", out)
+	}
+	if !strings.Contains(string(out), work) || strings.Contains(string(out), "root-writable") {
+		t.Errorf("unexpected directory or writable root: %s", out)
+	}
+	if strings.Contains(string(out), "leaked") {
+		t.Errorf("environment not scrubbed: %s", out)
+	}
+}
@@ -0,0 +1,823 @@
+// Package shellsafety classifies shell commands as read-only, mutating or
+// dangerous. Commands are parsed with a real shell parser and every simple
+// command (in pipelines, lists, subshells and substitutions) is checked
+// against allow and deny lists; the command as a whole gets the highest level.
+package shellsafety
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Level is how much a command may change
+type Level int
+
+const (
+	ReadOnly  Level = iota // only reads files or state
+	Mutating               // may change files or state (or is not known to be read-only)
+	Dangerous              // destructive, irreversible or runs remote code
+)
+
+func (l Level) String() string {
+	switch l {
+	case ReadOnly:
+		return "read-only"
+	case Mutating:
+		return "mutating"
+	default:
+		return "dangerous"
+	}
+}
+
+// Result is the classification of a command line
+type Result struct {
+	Level  Level
+	Reason string // why the command got its level (empty for read-only)
+}
+
+// Classify parses script as bash and classifies it
+func Classify(script string) Result {
+	f, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(script), "")
+	if err != nil {
+		return Result{Level: Mutating, Reason: "could not parse the command: " + err.Error()}
+	}
+	c := &classifier{}
+	c.node(f)
+	return c.result
+}
+
+type classifier struct {
+	result Result
+	depth  int // nesting of sh -c strings
+}
+
+func (c *classifier) raise(level Level, format string, args ...interface{}) {
+	if level > c.result.Level {
+		c.result = Result{Level: level, Reason: fmt.Sprintf(format, args...)}
+	}
+}
+
+func (c *classifier) node(n syntax.Node) {
+	syntax.Walk(n, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.CallExpr:
+			c.call(n)
+		case *syntax.Redirect:
+			c.redirect(n)
+		case *syntax.BinaryCmd:
+			if n.Op == syntax.Pipe || n.Op == syntax.PipeAll {
+				c.pipeline(n)
+			}
+		case *syntax.FuncDecl:
+			c.raise(Mutating, "defines a shell function")
+		}
+		return true
+	})
+}
+
+func (c *classifier) redirect(r *syntax.Redirect) {
+	if writesFile(r) {
+		c.raise(Mutating, "redirects output to a file")
+	}
+}
+
+// writesFile reports whether a redirection writes to a file rather than to
+// another descriptor or /dev/null, /dev/stdout and /dev/stderr
+func writesFile(r *syntax.Redirect) bool {
+	switch r.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll, syntax.RdrInOut:
+	case syntax.DplOut:
+		if target, ok := wordValue(r.Word); ok && (target == "-" || isDigits(target)) {
+			return false
+		}
+	default:
+		return false
+	}
+	target, ok := wordValue(r.Word)
+	return !ok || (target != "/dev/null" && target != "/dev/stdout" && target != "/dev/stderr")
+}
+
+// pipeline flags downloads piped into an interpreter (curl ... | sh)
+func (c *classifier) pipeline(b *syntax.BinaryCmd) {
+	var calls []*syntax.CallExpr
+	var collect func(s *syntax.Stmt)
+	collect = func(s *syntax.Stmt) {
+		switch cmd := s.Cmd.(type) {
+		case *syntax.BinaryCmd:
+			if cmd.Op == syntax.Pipe || cmd.Op == syntax.PipeAll {
+				collect(cmd.X)
+				collect(cmd.Y)
+				return
+			}
+		case *syntax.CallExpr:
+			calls = append(calls, cmd)
+		}
+	}
+	collect(&syntax.Stmt{Cmd: b})
+
+	downloaded := false
+	for _, call := range calls {
+		argv := words(call.Args)
+		name, args := unwrap(argv)
+		if downloaders[name] {
+			downloaded = true
+			continue
+		}
+		if downloaded && interpreters[name] && readsStdin(name, args) {
+			c.raise(Dangerous, "pipes downloaded content into %s", name)
+		}
+	}
+}
+
+func (c *classifier) call(call *syntax.CallExpr) {
+	if len(call.Args) == 0 {
+		return // only variable assignments
+	}
+	argv := words(call.Args)
+	if argv[0] == unknownWord {
+		c.raise(Mutating, "the command name is not a literal")
+		return
+	}
+
+	// Running downloaded code: bash -c "$(curl ...)", source <(wget ...)
+	name, _ := unwrap(argv)
+	if interpreters[name] || name == "eval" || name == "source" || name == "." {
+		for _, w := range call.Args[1:] {
+			if containsDownload(w) {
+				c.raise(Dangerous, "runs downloaded content with %s", name)
+			}
+		}
+	}
+
+	level, reason := c.classifyArgs(argv)
+	c.raise(level, "%s", reason)
+}
+
+// classifyArgs classifies one simple command
+func (c *classifier) classifyArgs(argv []string) (Level, string) {
+	if len(argv) == 0 {
+		return ReadOnly, ""
+	}
+	name := path.Base(argv[0])
+	args := argv[1:]
+
+	switch name {
+	case "sudo", "doas", "su", "pkexec":
+		return Dangerous, name + " runs commands with elevated privileges"
+	case "env", "time", "nice", "nohup", "timeout", "command", "builtin", "exec", "xargs", "stdbuf", "ionice":
+		if name == "command" && hasAny(args, "-v", "-V") {
+			return ReadOnly, ""
+		}
+		return c.classifyArgs(stripWrappers(argv))
+	case "sh", "bash", "zsh", "dash", "ksh":
+		for i, a := range args {
+			if a == "-c" && i+1 < len(args) {
+				if args[i+1] == unknownWord || c.depth > 3 {
+					return Mutating, name + " -c runs a script that is not a literal"
+				}
+				inner := &classifier{depth: c.depth + 1}
+				if f, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(args[i+1]), ""); err == nil {
+					inner.node(f)
+					return inner.result.Level, inner.result.Reason
+				}
+				return Mutating, "could not parse the script passed to " + name
+			}
+		}
+		return Mutating, name + " runs a script"
+	case "rm":
+		return classifyRm(args)
+	case "git":
+		return classifyGit(args)
+	case "find":
+		for _, a := range args {
+			switch a {
+			case "-delete":
+				return Mutating, "find -delete removes files"
+			case "-exec", "-execdir", "-ok", "-okdir", "-fprint", "-fprint0", "-fprintf", "-fls":
+				return Mutating, "find " + a + " may change files"
+			}
+		}
+		return ReadOnly, ""
+	case "sed":
+		return classifySed(args)
+	case "rg":
+		for _, a := range args {
+			if a == "--pre" || strings.HasPrefix(a, "--pre=") || strings.HasPrefix(a, "--pre-glob") {
+				return Mutating, "rg --pre runs a preprocessor command"
+			}
+		}
+		return ReadOnly, ""
+	case "yq":
+		for _, a := range args {
+			if strings.HasPrefix(a, "--inplace") || strings.HasPrefix(a, "--split-exp") {
+				return Mutating, "yq " + a + " writes files"
+			}
+		}
+		if hasFlagLetter(args, 'i') {
+			return Mutating, "yq -i edits files in place"
+		}
+		if hasFlagLetter(args, 's') {
+			return Mutating, "yq -s writes a file per document"
+		}
+		return ReadOnly, ""
+	case "tree":
+		if hasFlagLetter(args, 'o') {
+			return Mutating, "tree -o writes a file"
+		}
+		return ReadOnly, ""
+	case "sort":
+		if hasFlagLetter(args, 'o') || hasPrefixArg(args, "--output") {
+			return Mutating, "sort -o writes a file"
+		}
+		return ReadOnly, ""
+	case "uniq", "xxd":
+		// The second operand is the output file
+		if len(operands(name, args)) > 1 {
+			return Mutating, name + " writes its second operand"
+		}
+		return ReadOnly, ""
+	case "go":
+		if len(args) > 0 && goReadOnly[args[0]] && !hasAny(args, "-w", "-u") &&
+			!hasPrefixArg(args, "-vettool", "--vettool", "-toolexec", "--toolexec") {
+			return ReadOnly, ""
+		}
+		return Mutating, "go " + firstArg(args) + " may build or change files"
+	case "dd":
+		for _, a := range args {
+			if strings.HasPrefix(a, "of=/dev/") {
+				return Dangerous, "dd writes to a device"
+			}
+		}
+		return Mutating, "dd writes files"
+	case "chmod", "chown", "chgrp":
+		if hasAny(args, "-R", "--recursive") && hasAny(args, "/", "~", "/*") {
+			return Dangerous, name + " -R on the root or home directory"
+		}
+		return Mutating, name + " changes file permissions"
+	case "shutdown", "reboot", "halt", "poweroff", "mkfs", "fdisk", "parted", "wipefs", "shred":
+		return Dangerous, name + " is destructive"
+	}
+	if strings.HasPrefix(name, "mkfs.") {
+		return Dangerous, name + " formats a filesystem"
+	}
+	if readOnlyCommands[name] {
+		return ReadOnly, ""
+	}
+	return Mutating, name + " is not a known read-only command"
+}
+
+func classifyRm(args []string) (Level, string) {
+	recursive, force := false, false
+	var targets []string
+	for _, a := range args {
+		switch {
+		case a == "--recursive":
+			recursive = true
+		case a == "--force":
+			force = true
+		case strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--") && len(a) > 1:
+			recursive = recursive || strings.ContainsAny(a, "rR")
+			force = force || strings.Contains(a, "f")
+		default:
+			targets = append(targets, a)
+		}
+	}
+	if recursive {
+		for _, t := range targets {
+			switch strings.TrimSuffix(t, "/") {
+			case unknownWord:
+				return Dangerous, "rm -r of a path that is not a literal"
+			case "", "~", ".", "..", "*", "/*", "$HOME":
+				return Dangerous, "rm -r of " + t
+			}
+		}
+	}
+	if recursive && force {
+		return Dangerous, "rm -rf deletes files irreversibly"
+	}
+	return Mutating, "rm deletes files"
+}
+
+func classifyGit(args []string) (Level, string) {
+	// Configuration set on the command line can run programs (core.fsmonitor,
+	// diff.external, core.pager), so -c makes any subcommand mutating
+	opts, args := splitGitOptions(args)
+	if setsGitConfig(opts) {
+		return Mutating, "git " + strings.Join(opts, " ") + " sets configuration that can run commands"
+	}
+	if len(args) == 0 {
+		return ReadOnly, ""
+	}
+	sub, rest := args[0], args[1:]
+
+	for _, a := range rest {
+		if strings.HasPrefix(a, "--output") {
+			return Mutating, "git " + sub + " " + a + " writes a file"
+		}
+		if sub == "grep" && (strings.HasPrefix(a, "-O") || strings.HasPrefix(a, "--open-files-in-pager")) {
+			return Mutating, "git grep -O runs a pager command"
+		}
+	}
+
+	switch sub {
+	case "push":
+		return Dangerous, "git push publishes commits"
+	case "reset":
+		if hasAny(rest, "--hard", "--merge", "--keep") {
+			return Dangerous, "git reset " + firstFlag(rest, "--hard", "--merge", "--keep") + " discards changes"
+		}
+	case "clean":
+		if hasFlagLetter(rest, 'f') || hasAny(rest, "--force") {
+			return Dangerous, "git clean -f deletes untracked files"
+		}
+	case "checkout", "restore":
+		if hasAny(rest, ".", "-f", "--force", ":/") {
+			return Dangerous, "git " + sub + " discards local changes"
+		}
+	case "branch":
+		if hasFlagLetter(rest, 'D') {
+			return Dangerous, "git branch -D deletes a branch"
+		}
+		if !hasAny(rest, "-d", "--delete", "-m", "-M", "-c", "-C", "--move", "--copy", "-u", "--set-upstream-to", "--unset-upstream", "-f", "--force") &&
+			nonFlags(rest) == 0 {
+			return ReadOnly, ""
+		}
+	case "stash":
+		if len(rest) > 0 && (rest[0] == "drop" || rest[0] == "clear") {
+			return Dangerous, "git stash " + rest[0] + " deletes stashed changes"
+		}
+		if len(rest) > 0 && (rest[0] == "list" || rest[0] == "show") {
+			return ReadOnly, ""
+		}
+	case "tag":
+		if len(rest) == 0 || hasAny(rest, "-l", "--list") {
+			return ReadOnly, ""
+		}
+	case "remote":
+		if len(rest) == 0 || rest[0] == "-v" || rest[0] == "show" || rest[0] == "get-url" {
+			return ReadOnly, ""
+		}
+	case "config":
+		if hasAny(rest, "--get", "--get-all", "--get-regexp", "-l", "--list") {
+			return ReadOnly, ""
+		}
+	case "worktree":
+		if len(rest) > 0 && rest[0] == "list" {
+			return ReadOnly, ""
+		}
+	case "reflog":
+		if len(rest) == 0 || rest[0] == "show" {
+			return ReadOnly, ""
+		}
+	default:
+		if gitReadOnly[sub] {
+			return ReadOnly, ""
+		}
+	}
+	return Mutating, "git " + sub + " changes the repository"
+}
+
+// classifySed checks the options and scripts of a sed command. Besides -i,
+// GNU sed scripts can run commands (e, s///e) and write files (w, W, s///w).
+func classifySed(args []string) (Level, string) {
+	var scripts, operands []string
+	explicit := false // scripts given with -e
+	sandbox := false  // GNU sed rejects e, r and w commands, but still honours -i
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--sandbox":
+			sandbox = true
+		case strings.HasPrefix(a, "--in-place"):
+			return Mutating, "sed -i edits files in place"
+		case a == "--expression" && i+1 < len(args):
+			scripts = append(scripts, args[i+1])
+			explicit = true
+			i++
+		case strings.HasPrefix(a, "--expression="):
+			scripts = append(scripts, strings.TrimPrefix(a, "--expression="))
+			explicit = true
+		case strings.HasPrefix(a, "--file"):
+			return Mutating, "sed -f runs a script file that may write files"
+		case a == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+		case len(a) > 1 && a[0] == '-' && a[1] != '-':
+			for j := 1; j < len(a); j++ {
+				switch a[j] {
+				case 'i':
+					return Mutating, "sed -i edits files in place"
+				case 'f':
+					return Mutating, "sed -f runs a script file that may write files"
+				case 'e', 'l':
+					value := a[j+1:]
+					if value == "" && i+1 < len(args) {
+						value = args[i+1]
+						i++
+					}
+					if a[j] == 'e' {
+						scripts = append(scripts, value)
+						explicit = true
+					}
+					j = len(a)
+				}
+			}
+		case !strings.HasPrefix(a, "-"):
+			operands = append(operands, a)
+		}
+	}
+	if sandbox {
+		return ReadOnly, ""
+	}
+	if !explicit && len(operands) > 0 {
+		scripts = append(scripts, operands[0])
+	}
+	for _, script := range scripts {
+		if script == unknownWord {
+			return Mutating, "the sed script is not a literal"
+		}
+		if reason := sedScriptEffect(script); reason != "" {
+			return Mutating, reason
+		}
+	}
+	return ReadOnly, ""
+}
+
+// sedScriptEffect scans a sed script for commands that run programs or write
+// files, returning a reason for the first one found
+func sedScriptEffect(script string) string {
+	i := 0
+	// skipDelimited moves past text ending at an unescaped delim
+	skipDelimited := func(delim byte) {
+		for i < len(script) && script[i] != delim {
+			if script[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		i++
+	}
+	toLineEnd := func() {
+		for i < len(script) && script[i] != '\n' {
+			i++
+		}
+	}
+
+	for i < len(script) {
+		ch := script[i]
+		switch {
+		case strings.IndexByte(" \t\n;{}!,~+$0123456789IM", ch) >= 0:
+			i++
+		case ch == '/':
+			i++
+			skipDelimited('/')
+		case ch == '\\' && i+1 < len(script):
+			i += 2
+			skipDelimited(script[i-1])
+		case ch == 'e':
+			return "sed e runs a command"
+		case ch == 'w' || ch == 'W':
+			return "sed " + string(ch) + " writes a file"
+		case ch == 'a' || ch == 'i' || ch == 'c' || ch == 'r' || ch == 'R' || ch == '#':
+			toLineEnd() // text, file name or comment
+		case ch == ':' || ch == 'b' || ch == 't' || ch == 'T':
+			for i < len(script) && script[i] != ';' && script[i] != '\n' {
+				i++ // label
+			}
+		case (ch == 's' || ch == 'y') && i+1 < len(script):
+			delim := script[i+1]
+			i += 2
+			skipDelimited(delim)
+			skipDelimited(delim)
+			if ch == 'y' {
+				continue
+			}
+			for i < len(script) && strings.IndexByte(";\n}", script[i]) < 0 {
+				switch script[i] {
+				case 'e':
+					return "sed s///e runs the pattern space as a command"
+				case 'w':
+					return "sed s///w writes a file"
+				}
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	return ""
+}
+
+// splitGitOptions splits git arguments into the global options before the
+// subcommand (-C dir, -c key=value, --no-pager, --git-dir=...) and the rest
+func splitGitOptions(args []string) (opts, rest []string) {
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		switch args[i] {
+		case "-C", "-c", "--git-dir", "--work-tree", "--namespace":
+			i++ // the value
+		}
+		i++
+	}
+	if i > len(args) {
+		i = len(args)
+	}
+	return args[:i], args[i:]
+}
+
+// setsGitConfig reports whether git global options set configuration
+func setsGitConfig(opts []string) bool {
+	for _, o := range opts {
+		if o == "-c" || strings.HasPrefix(o, "--config-env") || strings.HasPrefix(o, "--exec-path=") {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownWord stands for an argument that is not a literal (e.g. "$VAR")
+const unknownWord = "\x00"
+
+func words(ws []*syntax.Word) []string {
+	out := make([]string, len(ws))
+	for i, w := range ws {
+		if v, ok := wordValue(w); ok {
+			out[i] = v
+		} else {
+			out[i] = unknownWord
+		}
+	}
+	return out
+}
+
+// wordValue returns the value of a word made only of literals and quotes,
+// with backslash escapes removed as the shell does, so `\rm` is "rm"
+func wordValue(w *syntax.Word) (string, bool) {
+	if w == nil {
+		return "", false
+	}
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(unescape(p.Value, false))
+		case *syntax.SglQuoted:
+			if p.Dollar {
+				return "", false // $'...' has its own escapes
+			}
+			sb.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			if p.Dollar {
+				return "", false
+			}
+			for _, dp := range p.Parts {
+				lit, ok := dp.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				sb.WriteString(unescape(lit.Value, true))
+			}
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+// unescape removes the backslashes of an unquoted literal, or of a literal in
+// double quotes, where only $, `, ", \ and newline can be escaped
+func unescape(s string, doubleQuoted bool) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		next := s[i+1]
+		switch {
+		case next == '\n':
+			i++ // line continuation
+		case !doubleQuoted || strings.IndexByte("$`\"\\", next) >= 0:
+			sb.WriteByte(next)
+			i++
+		default:
+			sb.WriteByte('\\')
+		}
+	}
+	return sb.String()
+}
+
+// unwrap returns the command run through wrappers such as env, time and xargs
+func unwrap(argv []string) (string, []string) {
+	argv = stripWrappers(argv)
+	if len(argv) == 0 {
+		return "", nil
+	}
+	return path.Base(argv[0]), argv[1:]
+}
+
+func stripWrappers(argv []string) []string {
+	for len(argv) > 0 {
+		name := path.Base(argv[0])
+		if !wrappers[name] {
+			return argv
+		}
+		argv = skipOptions(name, argv[1:])
+		if name == "timeout" && len(argv) > 0 {
+			argv = argv[1:] // the duration
+		}
+	}
+	return argv
+}
+
+// skipOptions drops the options of a wrapper command (and env assignments)
+func skipOptions(wrapper string, argv []string) []string {
+	for len(argv) > 0 {
+		a := argv[0]
+		switch {
+		case wrapper == "env" && strings.Contains(a, "=") && !strings.HasPrefix(a, "-"):
+			argv = argv[1:]
+		case strings.HasPrefix(a, "-") && a != "-":
+			argv = argv[1:]
+			if len(argv) > 0 && optionTakesValue(wrapper, a) {
+				argv = argv[1:]
+			}
+		default:
+			return argv
+		}
+	}
+	return argv
+}
+
+func optionTakesValue(wrapper, opt string) bool {
+	switch wrapper {
+	case "env":
+		return opt == "-u" || opt == "-C"
+	case "nice":
+		return opt == "-n"
+	case "xargs":
+		return opt == "-I" || opt == "-n" || opt == "-P" || opt == "-L" || opt == "-d" || opt == "-s"
+	case "timeout":
+		return opt == "-s" || opt == "-k"
+	case "ionice":
+		return opt == "-c" || opt == "-n"
+	}
+	return false
+}
+
+// readsStdin reports whether an interpreter invocation executes its standard input
+func readsStdin(name string, args []string) bool {
+	for _, a := range args {
+		if a == "-" || a == "-s" {
+			return true
+		}
+		if a == "-c" || a == "-e" || !strings.HasPrefix(a, "-") {
+			return false
+		}
+	}
+	return true
+}
+
+func containsDownload(w *syntax.Word) bool {
+	found := false
+	syntax.Walk(w, func(n syntax.Node) bool {
+		if call, ok := n.(*syntax.CallExpr); ok {
+			if name, _ := unwrap(words(call.Args)); downloaders[name] {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+func hasAny(args []string, flags ...string) bool {
+	return firstFlag(args, flags...) != ""
+}
+
+func firstFlag(args []string, flags ...string) string {
+	for _, a := range args {
+		for _, f := range flags {
+			if a == f {
+				return f
+			}
+		}
+	}
+	return ""
+}
+
+// hasFlagLetter reports whether a short option cluster (like -fd) contains letter
+func hasFlagLetter(args []string, letter byte) bool {
+	for _, a := range args {
+		if len(a) > 1 && a[0] == '-' && a[1] != '-' && strings.IndexByte(a[1:], letter) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrefixArg reports whether an argument starts with one of prefixes
+func hasPrefixArg(args []string, prefixes ...string) bool {
+	for _, a := range args {
+		for _, p := range prefixes {
+			if strings.HasPrefix(a, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// operands returns the arguments of a command that are not options or
+// option values
+func operands(name string, args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			return append(out, args[i+1:]...)
+		case strings.HasPrefix(a, "-") && a != "-":
+			if len(a) == 2 && strings.IndexByte(valueOptions[name], a[1]) >= 0 {
+				i++
+			}
+		default:
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func nonFlags(args []string) int {
+	n := 0
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") {
+			n++
+		}
+	}
+	return n
+}
+
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func set(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, n := range names {
+		m[n] = true
+	}
+	return m
+}
+
+var (
+	readOnlyCommands = set(
+		"ls", "ll", "la", "cat", "head", "tail", "less", "more", "wc", "grep", "egrep", "fgrep", "rg", "ag",
+		"pwd", "echo", "printf", "which", "whereis", "type", "file", "stat", "du", "df", "tree",
+		"uniq", "cut", "tr", "diff", "cmp", "comm", "date", "cal", "whoami", "id", "groups", "uname",
+		"hostname", "basename", "dirname", "realpath", "readlink", "jq", "yq", "true", "false", "test", "[",
+		"cd", "pushd", "popd", "export", "set", "unset", "alias", "history", "ps", "top", "free", "uptime",
+		"nl", "od", "hexdump", "xxd", "strings", "md5sum", "sha1sum", "sha256sum", "shasum", "cksum",
+		"column", "fold", "fmt", "rev", "tac", "paste", "join", "expand", "unexpand", "seq", "sleep",
+		"printenv", "locale", "lsof", "tty", "man", "help", "nproc", "arch", "column", "zcat", "bzcat", "xzcat",
+	)
+
+	gitReadOnly = set(
+		"status", "diff", "log", "show", "blame", "grep", "ls-files", "ls-tree", "ls-remote", "rev-parse",
+		"rev-list", "describe", "shortlog", "cat-file", "name-rev", "merge-base", "whatchanged", "count-objects",
+		"for-each-ref", "show-ref", "show-branch", "check-ignore", "cherry", "range-diff", "version", "help",
+	)
+
+	goReadOnly = set("list", "version", "env", "doc", "vet")
+
+	// valueOptions lists the short options that take a separate value
+	valueOptions = map[string]string{
+		"uniq": "fsw",
+		"xxd":  "cglnos",
+	}
+
+	downloaders  = set("curl", "wget", "fetch")
+	interpreters = set("sh", "bash", "zsh", "dash", "ksh", "fish", "python", "python2", "python3", "perl", "ruby", "node", "php")
+	wrappers     = set("env", "time", "nice", "nohup", "timeout", "command", "builtin", "exec", "xargs", "stdbuf", "ionice")
+)
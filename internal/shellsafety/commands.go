@@ -0,0 +1,135 @@
+package shellsafety
+
+import (
+	"path"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Commands returns the arguments of every simple command in script, including
+// those in pipelines, lists, subshells, substitutions and literal `sh -c`
+// scripts. Wrappers such as env, timeout and xargs are removed, so
+// `env X=1 xargs rm` yields ["rm"]. Words that are not literals (variables,
+// substitutions) are returned as empty strings.
+func Commands(script string) ([][]string, error) {
+	f, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(script), "")
+	if err != nil {
+		return nil, err
+	}
+	var cmds [][]string
+	collectCommands(f, 0, &cmds)
+	return cmds, nil
+}
+
+func collectCommands(n syntax.Node, depth int, cmds *[][]string) {
+	syntax.Walk(n, func(n syntax.Node) bool {
+		call, ok := n.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		argv := stripWrappers(words(call.Args))
+		if len(argv) == 0 {
+			return true
+		}
+		for i, a := range argv {
+			if a == unknownWord {
+				argv[i] = ""
+			}
+		}
+		*cmds = append(*cmds, argv)
+
+		// Look into literal scripts run by a shell
+		switch argv[0] {
+		case "sh", "bash", "zsh", "dash", "ksh":
+			for i, a := range argv[1:] {
+				if a == "-c" && i+2 < len(argv) && depth < 3 {
+					if f, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(argv[i+2]), ""); err == nil {
+						collectCommands(f, depth+1, cmds)
+					}
+					break
+				}
+			}
+		}
+		return true
+	})
+}
+
+// RedirectsToFile reports whether script redirects output to a file, in any
+// of its commands or in literal `sh -c` scripts. Redirections to other
+// descriptors and to /dev/null, /dev/stdout and /dev/stderr don't count.
+func RedirectsToFile(script string) (bool, error) {
+	f, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(script), "")
+	if err != nil {
+		return false, err
+	}
+	return redirectsToFile(f, 0), nil
+}
+
+func redirectsToFile(n syntax.Node, depth int) bool {
+	found := false
+	syntax.Walk(n, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.Redirect:
+			if writesFile(n) {
+				found = true
+			}
+		case *syntax.CallExpr:
+			argv := stripWrappers(words(n.Args))
+			if len(argv) == 0 || depth >= 3 {
+				break
+			}
+			switch argv[0] {
+			case "sh", "bash", "zsh", "dash", "ksh":
+				for i, a := range argv[1:] {
+					if a == "-c" && i+2 < len(argv) {
+						if f, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(argv[i+2]), ""); err == nil && redirectsToFile(f, depth+1) {
+							found = true
+						}
+						break
+					}
+				}
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// CommandWords returns argv as command prefix rules compare it: the command
+// name without its directory and, for git, without the global options before
+// the subcommand. Options that set git configuration are only dropped if
+// skipConfig is set, since they can make any git command run programs: a rule
+// allowing "git status" must not cover `git -c core.pager=x status`, while a
+// rule excluding "git push" must still cover `git -c x=y push`.
+func CommandWords(argv []string, skipConfig bool) []string {
+	if len(argv) == 0 {
+		return argv
+	}
+	name := argv[0]
+	if name != "" {
+		name = path.Base(name)
+	}
+	words := append([]string{name}, argv[1:]...)
+	if name == "git" {
+		if opts, rest := splitGitOptions(argv[1:]); skipConfig || !setsGitConfig(opts) {
+			words = append([]string{name}, rest...)
+		}
+	}
+	return words
+}
+
+// HasPrefix reports whether argv starts with the words of prefix, e.g.
+// ["git", "status", "-s"] starts with "git status"
+func HasPrefix(argv []string, prefix string) bool {
+	fields := strings.Fields(prefix)
+	if len(fields) == 0 || len(fields) > len(argv) {
+		return false
+	}
+	for i, f := range fields {
+		if argv[i] != f {
+			return false
+		}
+	}
+	return true
+}
@@ -18,6 +18,7 @@ type LauncherApp struct {
 	chat     *service.ChatService  // not actively used, bound for Wails type generation
 	mcp      *service.MCPManager   // not actively used, bound for Wails type generation
 	session  *service.SessionService
+	ext      *service.ExtensionService // not actively used, bound for Wails type generation
 }
 
 // NewLauncherApp creates a new launcher-mode application
@@ -26,6 +27,7 @@ func NewLauncherApp() *LauncherApp {
 	mcpMgr := service.NewMCPManager(settings)
 	chat := service.NewChatService(settings, mcpMgr)
 	session := service.NewSessionService(nil) // read-only, no chat service
+	ext := service.NewExtensionService(settings, mcpMgr)
 
 	mode := service.NewModeService("launcher", "", "")
 
@@ -35,6 +37,7 @@ func NewLauncherApp() *LauncherApp {
 		chat:     chat,
 		mcp:      mcpMgr,
 		session:  session,
+		ext:      ext,
 	}
 }
 
@@ -28,23 +28,41 @@ func main() {
 
 	// Only parse custom flags if not in dev mode
 	var workDirVal, sessionIDVal string
+	var includeDirs stringList
 	if !isDevMode {
 		workDir := flag.String("workdir", "", "Working directory for chat mode")
 		sessionID := flag.String("session", "", "Session ID to restore")
+		flag.Var(&includeDirs, "include-directories", "Additional workspace directories (comma-separated, repeatable)")
 		flag.Parse()
 		workDirVal = *workDir
 		sessionIDVal = *sessionID
 	}
 
 	if workDirVal != "" {
-		runChatMode(workDirVal, sessionIDVal)
+		runChatMode(workDirVal, sessionIDVal, includeDirs)
 	} else {
 		runLauncherMode()
 	}
 }
 
-func runChatMode(workDir, sessionID string) {
-	app := NewChatApp(workDir, sessionID)
+// stringList is a flag value that accepts repeated and comma-separated values
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+func runChatMode(workDir, sessionID string, includeDirs []string) {
+	app := NewChatApp(workDir, sessionID, includeDirs)
 
 	title := filepath.Base(workDir) + " - gmn-gui"
 
@@ -65,6 +83,7 @@ func runChatMode(workDir, sessionID string) {
 			app.chat,
 			app.mcp,
 			app.session,
+			app.ext,
 		},
 	})
 
@@ -93,6 +112,7 @@ func runLauncherMode() {
 			app.chat,
 			app.mcp,
 			app.session,
+			app.ext,
 		},
 	})
 
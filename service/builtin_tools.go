@@ -4,44 +4,86 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
-	"runtime"
 	"strings"
 	"time"
 
 	"github.com/tomohiro-owada/gmn-gui/internal/api"
-	"github.com/tomohiro-owada/gmn-gui/internal/envutil"
+	"github.com/tomohiro-owada/gmn-gui/internal/glob"
+	"github.com/tomohiro-owada/gmn-gui/internal/shellsafety"
 )
 
-// builtinToolNames lists all built-in tool names for routing
-var builtinToolNames = map[string]bool{
-	"run_shell_command":  true,
-	"read_file":          true,
-	"read_many_files":    true,
-	"write_file":         true,
-	"replace":            true,
-	"list_directory":     true,
-	"glob":               true,
-	"grep_search":        true,
-	"google_web_search":  true,
-	"web_fetch":          true,
-	"write_todos":        true,
-	"save_memory":        true,
-	"ask_user":           true,
-	"activate_skill":     true,
-	"get_internal_docs":  true,
+// builtinTools maps each built-in tool name to its implementation
+var builtinTools = map[string]toolHandler{
+	"run_shell_command": func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error) {
+		return textResult(execShellCommand(withSandbox(ctx, sandboxOptions(env.settings, env.ws)), env.ws, args))
+	},
+	"read_process_output": processTool("read_process_output"),
+	"list_processes":      processTool("list_processes"),
+	"kill_process":        processTool("kill_process"),
+	"read_tool_output": func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error) {
+		return textResult(execReadToolOutput(ctx, args))
+	},
+	"read_file": func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error) {
+		return execReadFile(env.ws, args)
+	},
+	"read_many_files": workspaceTool(execReadManyFiles),
+	"write_file":      workspaceTool(execWriteFile),
+	"replace":         workspaceTool(execReplace),
+	"multi_edit":      workspaceTool(execMultiEdit),
+	"apply_patch":     workspaceTool(execApplyPatch),
+	"list_directory":  workspaceTool(execListDirectory),
+	"glob":            workspaceTool(execGlob),
+	"grep_search": func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error) {
+		return textResult(execGrepSearch(ctx, env.ws, args))
+	},
+	"google_web_search": func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error) {
+		return textResult(execGoogleWebSearch(ctx, args, env.settings))
+	},
+	"web_fetch": func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error) {
+		return textResult(execWebFetch(ctx, args, env.settings))
+	},
+	"write_todos":    workDirTool(execWriteTodos),
+	"save_memory":    workDirTool(execSaveMemory),
+	"activate_skill": workDirTool(execActivateSkill),
+	"ask_user": func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error) {
+		return ToolResult{}, fmt.Errorf("ask_user should be handled by ChatService directly")
+	},
+	"get_internal_docs": workDirTool(execGetInternalDocs),
+}
+
+// workspaceTool adapts a text tool that works on the workspace
+func workspaceTool(fn func(*Workspace, map[string]interface{}) (string, error)) toolHandler {
+	return func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error) {
+		return textResult(fn(env.ws, args))
+	}
+}
+
+// workDirTool adapts a text tool that only needs the working directory
+func workDirTool(fn func(string, map[string]interface{}) (string, error)) toolHandler {
+	return func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error) {
+		return textResult(fn(env.ws.WorkDir, args))
+	}
+}
+
+func processTool(name string) toolHandler {
+	return func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error) {
+		return textResult(execProcessTool(ctx, name, args))
+	}
 }
 
 // IsBuiltinTool returns true if the tool name is a built-in tool
 func IsBuiltinTool(name string) bool {
-	return builtinToolNames[name]
+	_, ok := builtinTools[name]
+	return ok
 }
 
 // BuiltinToolDeclarations returns the function declarations for all built-in tools
@@ -49,7 +91,7 @@ func BuiltinToolDeclarations() []api.FunctionDecl {
 	return []api.FunctionDecl{
 		{
 			Name:        "run_shell_command",
-			Description: "Executes a shell command as `bash -c <command>`. Returns the combined stdout/stderr output, exit code if non-zero, and any error information. Use this for running build commands, tests, git operations, and other CLI tasks.",
+			Description: "Executes a shell command as `bash -c <command>`. Returns the combined stdout/stderr output, exit code if non-zero, and any error information. Use this for running build commands, tests, git operations, and other CLI tasks. Set is_background for long-running commands such as dev servers or watch tasks; it returns a process ID for read_process_output and kill_process.",
 			Parameters: jsonRaw(`{
 				"type": "object",
 				"properties": {
@@ -63,21 +105,88 @@ func BuiltinToolDeclarations() []api.FunctionDecl {
 					},
 					"dir_path": {
 						"type": "string",
-						"description": "Optional: Directory to run the command in. Defaults to the working directory."
+						"description": "Optional: Directory to run the command in, absolute or relative to the working directory and inside the workspace. Defaults to the working directory."
+					},
+					"timeout": {
+						"type": "number",
+						"description": "Optional: Timeout in milliseconds (default 120000). The command and its child processes are killed when it expires."
+					},
+					"is_background": {
+						"type": "boolean",
+						"description": "Optional: Run the command in the background and return immediately with a process ID. The timeout does not apply."
 					}
 				},
 				"required": ["command"]
 			}`),
 		},
+		{
+			Name:        "read_process_output",
+			Description: "Returns the output a background process (started with run_shell_command is_background) produced since the last read, and whether it is still running.",
+			Parameters: jsonRaw(`{
+				"type": "object",
+				"properties": {
+					"process_id": {
+						"type": "number",
+						"description": "The ID returned when the process was started."
+					},
+					"all": {
+						"type": "boolean",
+						"description": "Optional: Return all retained output (the last 256KB) instead of only new output."
+					}
+				},
+				"required": ["process_id"]
+			}`),
+		},
+		{
+			Name:        "list_processes",
+			Description: "Lists the background processes started in this session with their status and command.",
+			Parameters:  jsonRaw(`{"type": "object", "properties": {}}`),
+		},
+		{
+			Name:        "kill_process",
+			Description: "Stops a background process and all processes it started.",
+			Parameters: jsonRaw(`{
+				"type": "object",
+				"properties": {
+					"process_id": {
+						"type": "number",
+						"description": "The ID returned when the process was started."
+					}
+				},
+				"required": ["process_id"]
+			}`),
+		},
+		{
+			Name:        "read_tool_output",
+			Description: "Reads a stored tool output. Outputs longer than 40KB are shortened to their start and end, with a note naming the handle that holds the full output; use this to page through the omitted lines.",
+			Parameters: jsonRaw(`{
+				"type": "object",
+				"properties": {
+					"handle": {
+						"type": "string",
+						"description": "The handle named in the shortened output, e.g. \"output-1\"."
+					},
+					"offset": {
+						"type": "number",
+						"description": "Optional: The 0-based line number to start reading from."
+					},
+					"limit": {
+						"type": "number",
+						"description": "Optional: Maximum number of lines to read (default 500)."
+					}
+				},
+				"required": ["handle"]
+			}`),
+		},
 		{
 			Name:        "read_file",
-			Description: "Reads and returns the content of a specified file. For text files, it can read specific line ranges using offset and limit parameters.",
+			Description: "Reads and returns the content of a specified file. For text files, it can read specific line ranges using offset and limit parameters. Images (PNG, JPEG, GIF, WEBP, ...), PDFs, audio and video files are returned as inline data you can see or hear (max 20MB).",
 			Parameters: jsonRaw(`{
 				"type": "object",
 				"properties": {
 					"file_path": {
 						"type": "string",
-						"description": "The absolute path to the file to read."
+						"description": "The path to the file to read. Relative paths are resolved against the working directory; paths outside the workspace are rejected."
 					},
 					"offset": {
 						"type": "number",
@@ -99,7 +208,7 @@ func BuiltinToolDeclarations() []api.FunctionDecl {
 				"properties": {
 					"file_path": {
 						"type": "string",
-						"description": "The absolute path to the file to write."
+						"description": "The path to the file to write. Relative paths are resolved against the working directory; paths outside the workspace are rejected."
 					},
 					"content": {
 						"type": "string",
@@ -111,13 +220,13 @@ func BuiltinToolDeclarations() []api.FunctionDecl {
 		},
 		{
 			Name:        "replace",
-			Description: "Replaces text within a file. Finds the exact literal old_string and replaces it with new_string. Always read the file first to get the exact text to replace. Include enough context (at least 3 lines before and after) to uniquely identify the location.",
+			Description: "Replaces text within a file. Finds the exact literal old_string and replaces it with new_string. Always read the file first to get the exact text to replace. Include enough context (at least 3 lines before and after) to uniquely identify the location. If there is no exact match, whole lines are matched ignoring line endings, indentation and runs of whitespace, and the result says so.",
 			Parameters: jsonRaw(`{
 				"type": "object",
 				"properties": {
 					"file_path": {
 						"type": "string",
-						"description": "The absolute path to the file to modify."
+						"description": "The path to the file to modify. Relative paths are resolved against the working directory; paths outside the workspace are rejected."
 					},
 					"old_string": {
 						"type": "string",
@@ -135,6 +244,56 @@ func BuiltinToolDeclarations() []api.FunctionDecl {
 				"required": ["file_path", "old_string", "new_string"]
 			}`),
 		},
+		{
+			Name:        "multi_edit",
+			Description: "Applies several replacements to a single file in one call. Edits are applied in order, each to the result of the previous one, with the same matching rules as replace. Either all edits succeed and the file is written, or none are applied.",
+			Parameters: jsonRaw(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "The path to the file to modify. Relative paths are resolved against the working directory; paths outside the workspace are rejected."
+					},
+					"edits": {
+						"type": "array",
+						"description": "The edits to apply, in order.",
+						"items": {
+							"type": "object",
+							"properties": {
+								"old_string": {
+									"type": "string",
+									"description": "The exact literal text to replace."
+								},
+								"new_string": {
+									"type": "string",
+									"description": "The replacement text."
+								},
+								"expected_replacements": {
+									"type": "number",
+									"description": "Optional: Number of replacements expected. Defaults to 1."
+								}
+							},
+							"required": ["old_string", "new_string"]
+						}
+					}
+				},
+				"required": ["file_path", "edits"]
+			}`),
+		},
+		{
+			Name:        "apply_patch",
+			Description: "Applies a unified diff (as produced by git diff or diff -u) that may touch several files, including creating (--- /dev/null), deleting (+++ /dev/null) and renaming (rename from/rename to) files. Hunks are located near their header line numbers, tolerating shifted lines and whitespace differences. If any hunk fails, no file is changed. Returns the result of each hunk.",
+			Parameters: jsonRaw(`{
+				"type": "object",
+				"properties": {
+					"patch": {
+						"type": "string",
+						"description": "The unified diff. File paths are relative to the working directory (a/ and b/ prefixes are stripped)."
+					}
+				},
+				"required": ["patch"]
+			}`),
+		},
 		{
 			Name:        "list_directory",
 			Description: "Lists files and subdirectories in a specified directory. Returns names with a trailing / for directories.",
@@ -143,7 +302,7 @@ func BuiltinToolDeclarations() []api.FunctionDecl {
 				"properties": {
 					"dir_path": {
 						"type": "string",
-						"description": "The absolute path to the directory to list."
+						"description": "The path to the directory to list. Relative paths are resolved against the working directory; paths outside the workspace are rejected."
 					}
 				},
 				"required": ["dir_path"]
@@ -151,7 +310,7 @@ func BuiltinToolDeclarations() []api.FunctionDecl {
 		},
 		{
 			Name:        "glob",
-			Description: "Finds files matching a glob pattern (e.g. '**/*.ts', 'src/**/*.go'). Returns absolute paths sorted by modification time (newest first).",
+			Description: "Finds files matching a glob pattern (e.g. '**/*.ts', 'src/**/*.{ts,tsx}'). Supports **, {a,b} alternatives and [a-z] character classes. Returns paths sorted by modification time: files changed in the last 24 hours first (newest first), then the rest alphabetically. Files excluded by .gitignore or .geminiignore are skipped.",
 			Parameters: jsonRaw(`{
 				"type": "object",
 				"properties": {
@@ -169,7 +328,7 @@ func BuiltinToolDeclarations() []api.FunctionDecl {
 		},
 		{
 			Name:        "grep_search",
-			Description: "Searches for a regex pattern within file contents. Returns matching lines with file paths and line numbers. Max 100 matches.",
+			Description: "Searches for a regex pattern within file contents. Returns matching lines as path:line:text (context lines as path-line-text, blocks separated by --), matching file paths, or per-file match counts. Results are paginated with offset/limit. Files excluded by .gitignore or .geminiignore are skipped.",
 			Parameters: jsonRaw(`{
 				"type": "object",
 				"properties": {
@@ -184,6 +343,39 @@ func BuiltinToolDeclarations() []api.FunctionDecl {
 					"include": {
 						"type": "string",
 						"description": "Optional: Glob pattern to filter files (e.g. '*.js', '*.{ts,tsx}')."
+					},
+					"case_sensitive": {
+						"type": "boolean",
+						"description": "Optional: Whether the search is case-sensitive. Defaults to true."
+					},
+					"output_mode": {
+						"type": "string",
+						"enum": ["content", "files_with_matches", "count"],
+						"description": "Optional: 'content' (matching lines, default), 'files_with_matches' (file paths only) or 'count' (matches per file)."
+					},
+					"before": {
+						"type": "integer",
+						"description": "Optional: Lines of context to show before each match (content mode)."
+					},
+					"after": {
+						"type": "integer",
+						"description": "Optional: Lines of context to show after each match (content mode)."
+					},
+					"context": {
+						"type": "integer",
+						"description": "Optional: Lines of context to show before and after each match (content mode)."
+					},
+					"multiline": {
+						"type": "boolean",
+						"description": "Optional: Let the pattern span lines ('.' matches newlines, ^/$ match at line boundaries)."
+					},
+					"offset": {
+						"type": "integer",
+						"description": "Optional: Number of output lines to skip, for paging through large results."
+					},
+					"limit": {
+						"type": "integer",
+						"description": "Optional: Maximum number of output lines to return. Defaults to 500."
 					}
 				},
 				"required": ["pattern"]
@@ -361,20 +553,73 @@ func BuiltinToolDeclarations() []api.FunctionDecl {
 
 // planModeTools lists tool names allowed in plan mode (read-only)
 var planModeTools = map[string]bool{
-	"glob":               true,
-	"grep_search":        true,
-	"read_file":          true,
-	"read_many_files":    true,
-	"list_directory":     true,
-	"google_web_search":  true,
-	"web_fetch":          true,
-	"ask_user":           true,
-	"get_internal_docs":  true,
+	"glob":                true,
+	"grep_search":         true,
+	"read_file":           true,
+	"read_many_files":     true,
+	"list_directory":      true,
+	"google_web_search":   true,
+	"web_fetch":           true,
+	"ask_user":            true,
+	"get_internal_docs":   true,
+	"read_process_output": true,
+	"list_processes":      true,
+	"read_tool_output":    true,
+}
+
+// mutatingTools change workspace files. They are denied in plan mode and never
+// run in parallel, even if listed in planModeTools or parallelTools, and their
+// changes are previewed as diffs on the tool call.
+var mutatingTools = map[string]bool{
+	"write_file":  true,
+	"replace":     true,
+	"multi_edit":  true,
+	"apply_patch": true,
+}
+
+// maxParallelToolCalls bounds how many tool calls of one response run at once
+const maxParallelToolCalls = 8
+
+// parallelTools only read, so consecutive calls to them run concurrently
+var parallelTools = map[string]bool{
+	"glob":                true,
+	"grep_search":         true,
+	"read_file":           true,
+	"read_many_files":     true,
+	"list_directory":      true,
+	"google_web_search":   true,
+	"web_fetch":           true,
+	"get_internal_docs":   true,
+	"read_process_output": true,
+	"list_processes":      true,
+	"read_tool_output":    true,
 }
 
 // IsPlanModeTool returns true if the tool is allowed in plan mode
 func IsPlanModeTool(name string) bool {
-	return planModeTools[name]
+	return planModeTools[name] && !mutatingTools[name]
+}
+
+// PlanModeDenial returns why a tool call is not allowed in plan mode, or an
+// empty string if it is. Shell commands are allowed when they are read-only.
+func PlanModeDenial(name string, args map[string]interface{}) string {
+	if mutatingTools[name] {
+		return fmt.Sprintf("tool %q changes files and is not allowed in Plan Mode. Only read-only tools are available.", name)
+	}
+	if planModeTools[name] {
+		return ""
+	}
+	if name == "run_shell_command" {
+		if background, _ := args["is_background"].(bool); background {
+			return "background processes are not allowed in Plan Mode"
+		}
+		command, _ := args["command"].(string)
+		if res := shellsafety.Classify(command); res.Level != shellsafety.ReadOnly {
+			return fmt.Sprintf("only read-only shell commands are allowed in Plan Mode, and this one is %s: %s", res.Level, res.Reason)
+		}
+		return ""
+	}
+	return fmt.Sprintf("tool %q is not allowed in Plan Mode. Only read-only tools are available.", name)
 }
 
 // PlanModeToolDeclarations returns only read-only tool declarations for plan mode
@@ -382,7 +627,12 @@ func PlanModeToolDeclarations() []api.FunctionDecl {
 	all := BuiltinToolDeclarations()
 	var filtered []api.FunctionDecl
 	for _, decl := range all {
-		if planModeTools[decl.Name] {
+		if decl.Name == "run_shell_command" {
+			decl.Description = "Executes a read-only shell command as `bash -c <command>` (e.g. `ls`, `git status`, `git log`, `go list`). In Plan Mode commands that may change files or state, redirect output to files or run in the background are rejected."
+			filtered = append(filtered, decl)
+			continue
+		}
+		if planModeTools[decl.Name] && !mutatingTools[decl.Name] {
 			filtered = append(filtered, decl)
 		}
 	}
@@ -393,155 +643,227 @@ func jsonRaw(s string) json.RawMessage {
 	return json.RawMessage(s)
 }
 
-// ExecuteBuiltinTool runs a built-in tool and returns the result
-func ExecuteBuiltinTool(ctx context.Context, workDir string, name string, args map[string]interface{}, settings *SettingsService) (string, error) {
-	switch name {
-	case "run_shell_command":
-		return execShellCommand(ctx, workDir, args)
-	case "read_file":
-		return execReadFile(args)
-	case "read_many_files":
-		return execReadManyFiles(workDir, args)
-	case "write_file":
-		return execWriteFile(args)
-	case "replace":
-		return execReplace(args)
-	case "list_directory":
-		return execListDirectory(args)
-	case "glob":
-		return execGlob(workDir, args)
-	case "grep_search":
-		return execGrepSearch(ctx, workDir, args)
-	case "google_web_search":
-		return execGoogleWebSearch(ctx, args, settings)
-	case "web_fetch":
-		return execWebFetch(ctx, args, settings)
-	case "write_todos":
-		return execWriteTodos(workDir, args)
-	case "save_memory":
-		return execSaveMemory(workDir, args)
-	case "ask_user":
-		return "", fmt.Errorf("ask_user should be handled by ChatService directly")
-	case "activate_skill":
-		return execActivateSkill(workDir, args)
-	case "get_internal_docs":
-		return execGetInternalDocs(workDir, args)
-	default:
-		return "", fmt.Errorf("unknown built-in tool: %s", name)
-	}
+// ToolResult is the output of a tool call. InlineData carries file content
+// (images, PDFs, audio) that is sent to the model next to the function response.
+type ToolResult struct {
+	Output     string
+	InlineData *api.InlineData
 }
 
-// --- Tool implementations ---
+// textResult wraps a text-only tool output
+func textResult(output string, err error) (ToolResult, error) {
+	return ToolResult{Output: output}, err
+}
 
-func execShellCommand(ctx context.Context, workDir string, args map[string]interface{}) (string, error) {
-	command, _ := args["command"].(string)
-	if command == "" {
-		return "", fmt.Errorf("command is required")
+// ExecuteBuiltinTool runs a built-in tool and returns the result. Calls denied
+// by the tool policy in settings are refused.
+func ExecuteBuiltinTool(ctx context.Context, ws *Workspace, name string, args map[string]interface{}, settings *SettingsService) (ToolResult, error) {
+	if settings != nil {
+		if reason := NewToolPolicy(settings.GetConfig()).Check(name, args); reason != "" {
+			return ToolResult{}, fmt.Errorf("%s", reason)
+		}
 	}
-
-	dir := workDir
-	if d, ok := args["dir_path"].(string); ok && d != "" {
-		dir = d
+	handler, ok := builtinTools[name]
+	if !ok {
+		return ToolResult{}, fmt.Errorf("unknown built-in tool: %s", name)
 	}
+	return handler(ctx, toolEnv{ws: ws, settings: settings}, args)
+}
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
+// --- Tool implementations ---
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		// Windows: use cmd /c for simple commands, powershell for complex ones
-		cmd = exec.CommandContext(timeoutCtx, "powershell", "-NoProfile", "-Command", command)
-	} else {
-		// Unix-like: use bash
-		cmd = exec.CommandContext(timeoutCtx, "bash", "-c", command)
-	}
+// maxInlineFileSize is the largest image/PDF/audio/video file read_file sends to the model
+const maxInlineFileSize = 20 << 20
 
-	if dir != "" {
-		cmd.Dir = dir
+func execReadFile(ws *Workspace, args map[string]interface{}) (ToolResult, error) {
+	filePath, _ := args["file_path"].(string)
+	if filePath == "" {
+		return ToolResult{}, fmt.Errorf("file_path is required")
+	}
+	filePath, err := ws.Resolve(filePath)
+	if err != nil {
+		return ToolResult{}, err
 	}
-	cmd.Env = envutil.ShellEnv()
-
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
 
-	err := cmd.Run()
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return ToolResult{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	if info.IsDir() {
+		return ToolResult{}, fmt.Errorf("%s is a directory; use list_directory instead", filePath)
+	}
 
-	result := out.String()
-	if len(result) > 50000 {
-		result = result[:50000] + "\n... (output truncated)"
+	// Sniff the head of a large file before reading all of it, so an oversized
+	// image or video is rejected without being loaded into memory
+	if info.Size() > maxInlineFileSize {
+		head, err := readHead(filePath, 8000)
+		if err != nil {
+			return ToolResult{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		if mimeType := detectMediaType(filePath, head); mimeType != "" {
+			return ToolResult{}, fmt.Errorf("%s is too large to read (%d bytes, limit %d MB)", filePath, info.Size(), maxInlineFileSize>>20)
+		}
+		if isBinaryContent(head) {
+			return ToolResult{Output: fmt.Sprintf("Cannot display content of binary file: %s", filePath)}, nil
+		}
+		f, err := os.Open(filePath)
+		if err != nil {
+			return ToolResult{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		defer f.Close()
+		return textResult(readTextFile(f, args))
 	}
 
+	data, err := os.ReadFile(filePath)
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return fmt.Sprintf("%s\nExit code: %d", result, exitErr.ExitCode()), nil
-		}
-		return result, fmt.Errorf("command error: %w", err)
+		return ToolResult{}, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	if result == "" {
-		result = "(empty output)"
+	// Images, PDFs, audio and video go to the model as inline data
+	if mimeType := detectMediaType(filePath, data); mimeType != "" {
+		if len(data) > maxInlineFileSize {
+			return ToolResult{}, fmt.Errorf("%s is too large to read (%d bytes, limit %d MB)", filePath, len(data), maxInlineFileSize>>20)
+		}
+		return ToolResult{
+			Output: fmt.Sprintf("Read %s file: %s (%d bytes)", mimeType, filePath, len(data)),
+			InlineData: &api.InlineData{
+				MimeType: mimeType,
+				Data:     base64.StdEncoding.EncodeToString(data),
+			},
+		}, nil
+	}
+	if isBinaryContent(data) {
+		return ToolResult{Output: fmt.Sprintf("Cannot display content of binary file: %s", filePath)}, nil
 	}
 
-	return result, nil
+	return textResult(readTextFile(bytes.NewReader(data), args))
 }
 
-func execReadFile(args map[string]interface{}) (string, error) {
-	filePath, _ := args["file_path"].(string)
-	if filePath == "" {
-		return "", fmt.Errorf("file_path is required")
-	}
-
-	data, err := os.ReadFile(filePath)
+// readHead returns up to n bytes from the start of a file
+func readHead(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to read file: %w", err)
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, n)
+	m, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
 	}
+	return buf[:m], nil
+}
 
-	content := string(data)
-	lines := strings.Split(content, "\n")
+// maxTextReadSize is the most read_file returns of a text file read whole;
+// the rest can be read with offset and limit
+const maxTextReadSize = 20 << 20
 
-	// Handle offset/limit
+// readTextFile returns a text file's content, or its lines from offset up to
+// limit with line numbers. It stops reading after the selected lines and
+// after maxTextReadSize bytes, so a huge file is never loaded whole.
+func readTextFile(r io.Reader, args map[string]interface{}) (string, error) {
 	offset := 0
-	if o, ok := args["offset"].(float64); ok {
+	if o, ok := args["offset"].(float64); ok && o > 0 {
 		offset = int(o)
 	}
-	limit := len(lines)
-	if l, ok := args["limit"].(float64); ok {
+	limit := -1
+	if l, ok := args["limit"].(float64); ok && l >= 0 {
 		limit = int(l)
 	}
 
-	if offset > 0 || limit < len(lines) {
-		end := offset + limit
-		if offset >= len(lines) {
+	if offset == 0 && limit < 0 {
+		data, err := io.ReadAll(io.LimitReader(r, maxTextReadSize+1))
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		if len(data) > maxTextReadSize {
+			return fmt.Sprintf("%s\n(file truncated after %d MB; use offset and limit to read the rest)", data[:maxTextReadSize], maxTextReadSize>>20), nil
+		}
+		return string(data), nil
+	}
+
+	br := bufio.NewReader(r)
+	for n := 0; n < offset; n++ {
+		if err := skipLine(br); err == io.EOF {
 			return "(offset beyond end of file)", nil
+		} else if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
 		}
-		if end > len(lines) {
-			end = len(lines)
+	}
+
+	// Like strings.Split, the text after the last newline is a line too
+	rest := bufio.NewReader(io.LimitReader(br, maxTextReadSize+1))
+	var lines []string
+	size, eof := 0, false
+	for !eof && (limit < 0 || len(lines) < limit) {
+		line, err := rest.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read file: %w", err)
 		}
-		lines = lines[offset:end]
+		lines = append(lines, line)
+		size += len(line)
+		eof = err == io.EOF
+	}
+	truncated := size > maxTextReadSize
+	if offset == 0 && eof && !truncated {
+		return strings.Join(lines, ""), nil
+	}
 
-		// Add line numbers
-		var sb strings.Builder
-		for i, line := range lines {
-			sb.WriteString(fmt.Sprintf("%d: %s\n", offset+i+1, line))
+	var sb strings.Builder
+	for i, line := range lines {
+		sb.WriteString(fmt.Sprintf("%d: %s\n", offset+i+1, strings.TrimSuffix(line, "\n")))
+	}
+	if truncated {
+		sb.WriteString(fmt.Sprintf("(output truncated after %d MB; use offset and limit to read the rest)\n", maxTextReadSize>>20))
+	}
+	return sb.String(), nil
+}
+
+// skipLine reads past the next newline, without holding a long line in memory
+func skipLine(br *bufio.Reader) error {
+	for {
+		_, err := br.ReadSlice('\n')
+		if err != bufio.ErrBufferFull {
+			return err
 		}
-		return sb.String(), nil
 	}
+}
 
-	// Truncate very large files
-	if len(content) > 100000 {
-		return content[:100000] + fmt.Sprintf("\n... (truncated, total %d bytes. Use offset/limit to read more.)", len(content)), nil
+// detectMediaType returns the MIME type of an image, PDF, audio or video file,
+// sniffed from its content with the extension as a fallback, or "" for other files.
+func detectMediaType(path string, data []byte) string {
+	mimeType := http.DetectContentType(data)
+	if mimeType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); byExt != "" {
+			mimeType = byExt
+		}
+	}
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
 	}
 
-	return content, nil
+	switch {
+	case mimeType == "image/svg+xml":
+		return "" // SVG is text; let the model read the markup
+	case mimeType == "application/pdf",
+		strings.HasPrefix(mimeType, "image/"),
+		strings.HasPrefix(mimeType, "audio/"),
+		strings.HasPrefix(mimeType, "video/"):
+		return mimeType
+	}
+	return ""
 }
 
-func execWriteFile(args map[string]interface{}) (string, error) {
+func execWriteFile(ws *Workspace, args map[string]interface{}) (string, error) {
 	filePath, _ := args["file_path"].(string)
 	content, _ := args["content"].(string)
 	if filePath == "" {
 		return "", fmt.Errorf("file_path is required")
 	}
+	filePath, err := ws.Resolve(filePath)
+	if err != nil {
+		return "", err
+	}
 
 	// Create parent directories
 	dir := filepath.Dir(filePath)
@@ -556,51 +878,15 @@ func execWriteFile(args map[string]interface{}) (string, error) {
 	return fmt.Sprintf("Successfully wrote %d bytes to %s", len(content), filePath), nil
 }
 
-func execReplace(args map[string]interface{}) (string, error) {
-	filePath, _ := args["file_path"].(string)
-	oldStr, _ := args["old_string"].(string)
-	newStr, _ := args["new_string"].(string)
-	if filePath == "" || oldStr == "" {
-		return "", fmt.Errorf("file_path and old_string are required")
-	}
-
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read file: %w", err)
-	}
-
-	content := string(data)
-	expectedReplacements := 1
-	if e, ok := args["expected_replacements"].(float64); ok {
-		expectedReplacements = int(e)
-	}
-
-	count := strings.Count(content, oldStr)
-	if count == 0 {
-		return "", fmt.Errorf("old_string not found in file. Make sure the text matches exactly including whitespace")
-	}
-
-	if expectedReplacements == 1 && count > 1 {
-		return "", fmt.Errorf("old_string matches %d locations. Include more context to uniquely identify the target, or set expected_replacements=%d", count, count)
-	}
-
-	if count != expectedReplacements {
-		return "", fmt.Errorf("expected %d replacements but found %d matches", expectedReplacements, count)
-	}
-
-	newContent := strings.Replace(content, oldStr, newStr, expectedReplacements)
-	if err := os.WriteFile(filePath, []byte(newContent), 0o644); err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
-	}
-
-	return fmt.Sprintf("Successfully replaced %d occurrence(s) in %s", expectedReplacements, filePath), nil
-}
-
-func execListDirectory(args map[string]interface{}) (string, error) {
+func execListDirectory(ws *Workspace, args map[string]interface{}) (string, error) {
 	dirPath, _ := args["dir_path"].(string)
 	if dirPath == "" {
 		return "", fmt.Errorf("dir_path is required")
 	}
+	dirPath, err := ws.Resolve(dirPath)
+	if err != nil {
+		return "", err
+	}
 
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
@@ -608,7 +894,12 @@ func execListDirectory(args map[string]interface{}) (string, error) {
 	}
 
 	var sb strings.Builder
+	ignored := 0
 	for _, entry := range entries {
+		if ws.Ignored(filepath.Join(dirPath, entry.Name()), entry.IsDir()) {
+			ignored++
+			continue
+		}
 		name := entry.Name()
 		if entry.IsDir() {
 			name += "/"
@@ -616,6 +907,10 @@ func execListDirectory(args map[string]interface{}) (string, error) {
 		sb.WriteString(name + "\n")
 	}
 
+	if ignored > 0 {
+		sb.WriteString(fmt.Sprintf("(%d ignored by .gitignore/.geminiignore)\n", ignored))
+	}
+
 	if sb.Len() == 0 {
 		return "(empty directory)", nil
 	}
@@ -623,77 +918,38 @@ func execListDirectory(args map[string]interface{}) (string, error) {
 	return sb.String(), nil
 }
 
-func execGlob(workDir string, args map[string]interface{}) (string, error) {
+func execGlob(ws *Workspace, args map[string]interface{}) (string, error) {
 	pattern, _ := args["pattern"].(string)
 	if pattern == "" {
 		return "", fmt.Errorf("pattern is required")
 	}
 
-	dir := workDir
-	if d, ok := args["dir_path"].(string); ok && d != "" {
-		dir = d
-	}
-	if dir == "" {
-		dir = "."
+	roots, err := ws.SearchRoots(args)
+	if err != nil {
+		return "", err
 	}
 
-	// Use cross-platform Go implementation instead of shell commands
-	var matches []string
-	excludeDirs := map[string]bool{
-		"node_modules": true,
-		".git":         true,
-		"vendor":       true,
-		"dist":         true,
-		"build":        true,
-		".next":        true,
-		".vscode":      true,
-	}
-
-	// Handle ** patterns
-	if strings.Contains(pattern, "**") {
-		// Recursive glob: walk directory tree
-		parts := strings.Split(pattern, "**")
-		baseName := ""
-		if len(parts) > 1 {
-			baseName = strings.TrimPrefix(strings.TrimPrefix(parts[1], "/"), "\\")
-		}
-
-		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil // Skip errors
-			}
+	// Absolute patterns are matched once; relative ones against every root
+	if filepath.IsAbs(pattern) {
+		roots = roots[:1]
+	}
 
-			// Skip excluded directories
-			if info.IsDir() {
-				if excludeDirs[info.Name()] {
-					return filepath.SkipDir
-				}
-				return nil
-			}
+	const maxResults = 200
+	opts := glob.Options{Skip: ws.Ignored, Limit: 5000}
 
-			// Match pattern
-			if baseName == "" || strings.HasSuffix(path, baseName) {
-				relPath, _ := filepath.Rel(dir, path)
-				matches = append(matches, filepath.ToSlash(relPath))
-			}
-			if len(matches) >= 200 {
-				return filepath.SkipAll
-			}
-			return nil
-		})
-		if err != nil && err != filepath.SkipAll {
-			return "", fmt.Errorf("walk failed: %w", err)
-		}
-	} else {
-		// Simple glob without **
-		fullPattern := filepath.Join(dir, pattern)
-		m, err := filepath.Glob(fullPattern)
+	var matches []string
+	rootOf := make(map[string]string)
+	for _, dir := range roots {
+		m, err := ws.Glob(dir, pattern, opts)
 		if err != nil {
 			return "", fmt.Errorf("glob failed: %w", err)
 		}
 		for _, match := range m {
-			relPath, _ := filepath.Rel(dir, match)
-			matches = append(matches, filepath.ToSlash(relPath))
+			if _, seen := rootOf[match]; seen || !ws.Contains(match) {
+				continue
+			}
+			rootOf[match] = dir
+			matches = append(matches, match)
 		}
 	}
 
@@ -701,139 +957,22 @@ func execGlob(workDir string, args map[string]interface{}) (string, error) {
 		return "(no matches found)", nil
 	}
 
-	if len(matches) > 200 {
-		matches = matches[:200]
-		return strings.Join(matches, "\n") + "\n... (truncated, 200+ matches)", nil
-	}
-
-	return strings.Join(matches, "\n"), nil
-}
-
-func execGrepSearch(ctx context.Context, workDir string, args map[string]interface{}) (string, error) {
-	pattern, _ := args["pattern"].(string)
-	if pattern == "" {
-		return "", fmt.Errorf("pattern is required")
-	}
-
-	dir := workDir
-	if d, ok := args["dir_path"].(string); ok && d != "" {
-		dir = d
-	}
-	if dir == "" {
-		dir = "."
-	}
-
-	includePattern, _ := args["include"].(string)
-
-	// Compile regex pattern
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		return "", fmt.Errorf("invalid regex pattern: %w", err)
-	}
-
-	// Cross-platform grep implementation
-	excludeDirs := map[string]bool{
-		"node_modules": true,
-		".git":         true,
-		"vendor":       true,
-		"dist":         true,
-		"build":        true,
-		".next":        true,
-	}
-
-	var results []string
-	matchCount := 0
-	maxMatches := 500
-
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-
-		// Skip excluded directories
-		if info.IsDir() {
-			if excludeDirs[info.Name()] {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Check file pattern filter
-		if includePattern != "" {
-			matched, _ := filepath.Match(includePattern, info.Name())
-			if !matched {
-				return nil
-			}
-		}
-
-		// Skip binary files (simple heuristic)
-		if isBinaryFile(path) {
-			return nil
-		}
-
-		// Search in file
-		file, err := os.Open(path)
-		if err != nil {
-			return nil
-		}
-		defer file.Close()
-
-		relPath, _ := filepath.Rel(dir, path)
-		scanner := bufio.NewScanner(file)
-		lineNum := 0
-
-		for scanner.Scan() {
-			lineNum++
-			line := scanner.Text()
-
-			if re.MatchString(line) {
-				results = append(results, fmt.Sprintf("%s:%d:%s", filepath.ToSlash(relPath), lineNum, line))
-				matchCount++
-
-				if matchCount >= maxMatches {
-					return filepath.SkipAll
-				}
-			}
-		}
-
-		return nil
-	})
+	glob.SortByModTime(matches)
 
-	if err != nil && err != filepath.SkipAll {
-		return "", fmt.Errorf("search failed: %w", err)
+	truncated := len(matches) > maxResults
+	if truncated {
+		matches = matches[:maxResults]
 	}
-
-	if len(results) == 0 {
-		return "(no matches found)", nil
+	lines := make([]string, len(matches))
+	for i, match := range matches {
+		lines[i] = displayPath(roots, rootOf[match], match)
 	}
 
-	result := strings.Join(results, "\n")
-	if len(result) > 50000 {
-		result = result[:50000] + "\n... (output truncated)"
+	if truncated {
+		return strings.Join(lines, "\n") + "\n... (truncated, 200+ matches)", nil
 	}
 
-	return result, nil
-}
-
-// matchGlobSuffix matches a filename against a glob suffix (e.g. "*.ts", "*.go")
-func matchGlobSuffix(name, pattern string) bool {
-	matched, _ := filepath.Match(pattern, name)
-	return matched
-}
-
-// isBinaryFile checks if a file is likely binary (simple heuristic)
-func isBinaryFile(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	textExts := map[string]bool{
-		".txt": true, ".md": true, ".go": true, ".js": true, ".ts": true,
-		".py": true, ".java": true, ".c": true, ".cpp": true, ".h": true,
-		".json": true, ".xml": true, ".yaml": true, ".yml": true, ".toml": true,
-		".html": true, ".css": true, ".scss": true, ".vue": true, ".jsx": true,
-		".tsx": true, ".rs": true, ".rb": true, ".php": true, ".sh": true,
-		".sql": true, ".proto": true, ".graphql": true, ".env": true,
-		".conf": true, ".cfg": true, ".ini": true, ".log": true,
-	}
-	return !textExts[ext] && ext != ""
+	return strings.Join(lines, "\n"), nil
 }
 
 func execSaveMemory(workDir string, args map[string]interface{}) (string, error) {
@@ -872,7 +1011,7 @@ func execSaveMemory(workDir string, args map[string]interface{}) (string, error)
 	return fmt.Sprintf("Saved to %s: %s", memFile, fact), nil
 }
 
-func execReadManyFiles(workDir string, args map[string]interface{}) (string, error) {
+func execReadManyFiles(ws *Workspace, args map[string]interface{}) (string, error) {
 	includeRaw, ok := args["include"].([]interface{})
 	if !ok || len(includeRaw) == 0 {
 		return "", fmt.Errorf("include patterns are required")
@@ -887,64 +1026,27 @@ func execReadManyFiles(workDir string, args map[string]interface{}) (string, err
 		}
 	}
 
-	dir := workDir
-	if dir == "" {
-		dir = "."
-	}
-
 	var sb strings.Builder
 	totalSize := 0
 	fileCount := 0
 	const maxTotalSize = 500000
 	const maxFiles = 100
 
-	excludeDirs := map[string]bool{
-		"node_modules": true,
-		".git":         true,
-		"vendor":       true,
-	}
-
 	for _, pat := range includeRaw {
 		pattern, ok := pat.(string)
 		if !ok {
 			continue
 		}
 
-		// Cross-platform: use filepath.Walk + glob matching instead of shell find
+		// Absolute patterns are matched once; relative ones against every root
 		var matches []string
-		if strings.Contains(pattern, "**") {
-			// Recursive pattern: walk directory tree
-			suffix := ""
-			if parts := strings.SplitN(pattern, "**", 2); len(parts) > 1 {
-				suffix = strings.TrimPrefix(strings.TrimPrefix(parts[1], "/"), "\\")
-			}
-			filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return nil
-				}
-				if info.IsDir() {
-					if excludeDirs[info.Name()] {
-						return filepath.SkipDir
-					}
-					return nil
-				}
-				if suffix == "" || matchGlobSuffix(info.Name(), suffix) {
-					matches = append(matches, path)
-				}
-				if len(matches) >= 200 {
-					return filepath.SkipAll
-				}
-				return nil
-			})
-		} else {
-			// Simple glob
-			globMatches, _ := filepath.Glob(filepath.Join(dir, pattern))
-			for _, m := range globMatches {
-				info, err := os.Stat(m)
-				if err == nil && !info.IsDir() {
-					matches = append(matches, m)
-				}
-			}
+		roots := ws.Roots
+		if filepath.IsAbs(pattern) {
+			roots = roots[:1]
+		}
+		for _, dir := range roots {
+			m, _ := ws.Glob(dir, pattern, glob.Options{Skip: ws.Ignored, Limit: maxFiles})
+			matches = append(matches, m...)
 		}
 
 		for _, match := range matches {
@@ -952,16 +1054,16 @@ func execReadManyFiles(workDir string, args map[string]interface{}) (string, err
 				continue
 			}
 
-			// Resolve to absolute path
-			fullPath := match
-			if !filepath.IsAbs(match) {
-				fullPath = filepath.Join(dir, match)
+			// Resolve to absolute path, skipping anything outside the workspace or ignored
+			fullPath, err := ws.Resolve(match)
+			if err != nil || ws.Ignored(fullPath, false) {
+				continue
 			}
 
-			// Check exclude patterns
+			// Check exclude patterns against the file name and the path within its root
 			excluded := false
 			for _, ep := range excludePatterns {
-				if matched, _ := filepath.Match(ep, filepath.Base(fullPath)); matched {
+				if glob.Match(ep, filepath.Base(fullPath)) || glob.Match(ep, ws.RelPath(fullPath)) {
 					excluded = true
 					break
 				}
@@ -971,7 +1073,7 @@ func execReadManyFiles(workDir string, args map[string]interface{}) (string, err
 			}
 
 			data, err := os.ReadFile(fullPath)
-			if err != nil {
+			if err != nil || isBinaryContent(data) {
 				continue
 			}
 
@@ -1167,10 +1269,6 @@ func execWebFetchLocal(ctx context.Context, urlStr string) (string, error) {
 		return "(empty response from URL)", nil
 	}
 
-	if len(text) > 100000 {
-		text = text[:100000] + "\n... (truncated)"
-	}
-
 	return fmt.Sprintf("Content from %s:\n\n%s", urlStr, text), nil
 }
 
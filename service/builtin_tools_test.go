@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -21,7 +23,7 @@ func TestBuiltinTools(t *testing.T) {
 			"command": "go version",
 			"timeout": 10000,
 		}
-		result, err := execShellCommand(ctx, workDir, args)
+		result, err := execShellCommand(ctx, NewWorkspace(workDir, nil, nil), args)
 		if err != nil {
 			t.Errorf("❌ Error: %v", err)
 		} else {
@@ -35,7 +37,7 @@ func TestBuiltinTools(t *testing.T) {
 		args := map[string]interface{}{
 			"pattern": "service/*.go",
 		}
-		result, err := execGlob(workDir, args)
+		result, err := execGlob(NewWorkspace(workDir, nil, nil), args)
 		if err != nil {
 			t.Errorf("❌ Error: %v", err)
 		} else {
@@ -65,7 +67,7 @@ func TestBuiltinTools(t *testing.T) {
 		}
 		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 		defer cancel()
-		result, err := execGrepSearch(ctx, workDir, args)
+		result, err := execGrepSearch(ctx, NewWorkspace(workDir, nil, nil), args)
 		if err != nil {
 			t.Errorf("❌ Error: %v", err)
 		} else {
@@ -98,3 +100,75 @@ func TestBuiltinTools(t *testing.T) {
 
 	fmt.Println("\n=== All tests completed ===")
 }
+
+func TestReadFileMedia(t *testing.T) {
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	os.WriteFile(filepath.Join(dir, "shot.dat"), png, 0o644)
+	os.WriteFile(filepath.Join(dir, "main.ts"), []byte("export const x = 1\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "blob.bin"), []byte{0, 1, 2, 3}, 0o644)
+	ws := NewWorkspace(dir, nil, nil)
+
+	res, err := execReadFile(ws, map[string]interface{}{"file_path": "shot.dat"})
+	if err != nil || res.InlineData == nil || res.InlineData.MimeType != "image/png" {
+		t.Fatalf("expected PNG inline data detected from content, got %+v (%v)", res, err)
+	}
+
+	res, err = execReadFile(ws, map[string]interface{}{"file_path": "main.ts"})
+	if err != nil || res.InlineData != nil || res.Output != "export const x = 1\n" {
+		t.Errorf("expected TypeScript source as text, got %+v (%v)", res, err)
+	}
+
+	res, err = execReadFile(ws, map[string]interface{}{"file_path": "blob.bin"})
+	if err != nil || res.InlineData != nil || !strings.Contains(res.Output, "binary file") {
+		t.Errorf("expected binary notice, got %+v (%v)", res, err)
+	}
+
+	// A sparse oversized image is rejected from its size and header alone
+	huge := filepath.Join(dir, "huge.png")
+	os.WriteFile(huge, png, 0o644)
+	os.Truncate(huge, maxInlineFileSize+1)
+	if _, err := execReadFile(ws, map[string]interface{}{"file_path": "huge.png"}); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("expected oversized image to be rejected, got %v", err)
+	}
+}
+
+func TestReadTextFile(t *testing.T) {
+	arg := func(offset, limit float64) map[string]interface{} {
+		return map[string]interface{}{"offset": offset, "limit": limit}
+	}
+	tests := []struct {
+		content string
+		args    map[string]interface{}
+		want    string
+	}{
+		{"a\nb\n", nil, "a\nb\n"},
+		{"a\nb\nc", arg(1, 1), "2: b\n"},
+		{"a\nb\nc", arg(1, 10), "2: b\n3: c\n"},
+		{"a\nb\n", arg(2, 5), "3: \n"},
+		{"a\nb", arg(2, 5), "(offset beyond end of file)"},
+		{"a\nb\n", arg(0, 10), "a\nb\n"},
+		{"a\nb\n", arg(0, 1), "1: a\n"},
+	}
+	for _, tt := range tests {
+		got, err := readTextFile(strings.NewReader(tt.content), tt.args)
+		if err != nil || got != tt.want {
+			t.Errorf("readTextFile(%q, %v) = %q (%v), want %q", tt.content, tt.args, got, err, tt.want)
+		}
+	}
+
+	// Lines of a file too large to read whole are read without loading the rest
+	dir := t.TempDir()
+	huge := filepath.Join(dir, "huge.log")
+	os.WriteFile(huge, []byte(strings.Repeat("line\n", 2000)), 0o644)
+	os.Truncate(huge, maxTextReadSize*2)
+	ws := NewWorkspace(dir, nil, nil)
+	res, err := execReadFile(ws, map[string]interface{}{"file_path": "huge.log", "offset": float64(1), "limit": float64(2)})
+	if err != nil || res.Output != "2: line\n3: line\n" {
+		t.Errorf("unexpected lines of a huge file: %q (%v)", res.Output, err)
+	}
+	res, err = execReadFile(ws, map[string]interface{}{"file_path": "huge.log"})
+	if err != nil || len(res.Output) > maxTextReadSize+200 || !strings.Contains(res.Output, "file truncated") {
+		t.Errorf("huge file not truncated: %d bytes (%v)", len(res.Output), err)
+	}
+}
@@ -7,29 +7,62 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tomohiro-owada/gmn-gui/internal/api"
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+	"github.com/tomohiro-owada/gmn-gui/internal/shellsafety"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // ChatMessage represents a message displayed in the UI
 type ChatMessage struct {
-	ID        string    `json:"id"`
-	Role      string    `json:"role"` // "user" | "model" | "tool_call" | "tool_result"
-	Content   string    `json:"content"`
-	ToolName  string    `json:"toolName,omitempty"`
-	ToolArgs  string    `json:"toolArgs,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
+	ID           string     `json:"id"`
+	Role         string     `json:"role"` // "user" | "model" | "tool_call" | "tool_result"
+	Content      string     `json:"content"`
+	Thoughts     string     `json:"thoughts,omitempty"` // model: thought summaries streamed before the answer
+	ToolName     string     `json:"toolName,omitempty"`
+	ToolArgs     string     `json:"toolArgs,omitempty"`
+	CallID       string     `json:"callId,omitempty"`       // tool_call and tool_result: the call a result belongs to
+	Diffs        []FileDiff `json:"diffs,omitempty"`        // file changes previewed (tool_call) or made (tool_result)
+	OutputHandle string     `json:"outputHandle,omitempty"` // stored full output of a shortened tool_result
+	Timestamp    time.Time  `json:"timestamp"`
+
+	// Why a model response ended abnormally (cut off, blocked by safety
+	// filters, ...); empty when it finished normally
+	FinishReason     string   `json:"finishReason,omitempty"`
+	FinishMessage    string   `json:"finishMessage,omitempty"`
+	BlockReason      string   `json:"blockReason,omitempty"` // the prompt was blocked
+	SafetyCategories []string `json:"safetyCategories,omitempty"`
+}
+
+// messageSeq makes message IDs unique when several are created within the
+// clock's resolution, as parallel tool calls do
+var messageSeq atomic.Uint64
+
+func newMessageID() string {
+	return fmt.Sprintf("msg-%d-%d", time.Now().UnixNano(), messageSeq.Add(1))
 }
 
 // ChatStreamEvent is emitted to the frontend during streaming
 type ChatStreamEvent struct {
-	Type     string `json:"type"` // "start" | "content" | "tool_call" | "tool_result" | "done" | "error"
-	Text     string `json:"text,omitempty"`
-	ToolName string `json:"toolName,omitempty"`
-	ToolArgs string `json:"toolArgs,omitempty"`
+	Type         string     `json:"type"` // "start" | "thought" | "content" | "tool_call" | "tool_result" | "done" | "error"
+	Text         string     `json:"text,omitempty"`
+	ToolName     string     `json:"toolName,omitempty"`
+	ToolArgs     string     `json:"toolArgs,omitempty"`
+	CallID       string     `json:"callId,omitempty"` // tool_result: the call the result belongs to
+	Diffs        []FileDiff `json:"diffs,omitempty"`
+	FinishReason string     `json:"finishReason,omitempty"` // done: why the last response ended
+}
+
+// ToolOutputEvent carries output of a running tool (emitted as "chat:tool_output")
+type ToolOutputEvent struct {
+	CallID   string `json:"callId"` // the tool_call message of the running call
+	ToolName string `json:"toolName"`
+	Text     string `json:"text"`
 }
 
 // AskUserQuestion represents a question sent to the user via ask_user tool
@@ -51,20 +84,35 @@ type ChatService struct {
 	ctx      context.Context
 	settings *SettingsService
 	mcp      *MCPManager
+	procs    *ProcessManager  // Background shell processes started by tools
+	tools    *ToolRegistry    // Built-in and discovered tools
+	outputs  *ToolOutputStore // Full text of tool outputs too long for the model
 	mu       sync.Mutex
 
 	// Conversation state
-	messages []ChatMessage  // UI display messages
-	history  []api.Content  // API request history
-	model    string         // Per-session model (overrides default)
-	workDir  string         // Working directory for this session
-	cancel   context.CancelFunc
+	messages    []ChatMessage       // UI display messages
+	history     []api.Content       // API request history
+	model       string              // Per-session model (overrides default)
+	thinking    *api.ThinkingConfig // Per-session thinking config (nil: model default)
+	workDir     string              // Working directory for this session
+	includeDirs []string            // Additional workspace roots for this session
+	changes     []LedgerEntry       // Files changed by tools in this session
+	cancel      context.CancelFunc
 
 	// ask_user channel
 	askUserCh chan string
 
 	// Plan mode
 	planMode bool
+
+	// Hooks
+	sessionStarted bool     // SessionStart hooks have run for this session
+	hookContext    []string // additional context from SessionStart hooks
+	stopHookActive bool     // the current turn was continued by a Stop hook
+
+	// Responses cut off by the output token limit
+	continuations int  // responses continued in the current turn
+	continuing    bool // the next response continues the last model message
 }
 
 // NewChatService creates a new chat service
@@ -72,9 +120,20 @@ func NewChatService(settings *SettingsService, mcp *MCPManager) *ChatService {
 	return &ChatService{
 		settings: settings,
 		mcp:      mcp,
+		procs:    NewProcessManager(),
+		tools:    NewToolRegistry(),
+		outputs:  NewToolOutputStore(),
 	}
 }
 
+// Shutdown stops every background process started by tools and deletes
+// the stored tool outputs
+func (c *ChatService) Shutdown() {
+	c.endSession("exit")
+	c.procs.KillAll()
+	c.outputs.Clear()
+}
+
 // GetModel returns the current session model
 func (c *ChatService) GetModel() string {
 	c.mu.Lock()
@@ -92,6 +151,34 @@ func (c *ChatService) SetModel(model string) {
 	c.model = model
 }
 
+// GetThinkingConfig returns the thinking config of the current session, or
+// nil if the model default applies
+func (c *ChatService) GetThinkingConfig() *api.ThinkingConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyThinkingConfig(c.thinking)
+}
+
+// SetThinkingConfig sets the thinking config for the current session; nil
+// restores the model default
+func (c *ChatService) SetThinkingConfig(cfg *api.ThinkingConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.thinking = copyThinkingConfig(cfg)
+}
+
+func copyThinkingConfig(cfg *api.ThinkingConfig) *api.ThinkingConfig {
+	if cfg == nil {
+		return nil
+	}
+	cp := *cfg
+	if cfg.ThinkingBudget != nil {
+		budget := *cfg.ThinkingBudget
+		cp.ThinkingBudget = &budget
+	}
+	return &cp
+}
+
 // GetWorkDir returns the current working directory
 func (c *ChatService) GetWorkDir() string {
 	c.mu.Lock()
@@ -106,6 +193,61 @@ func (c *ChatService) SetWorkDir(dir string) {
 	c.workDir = dir
 }
 
+// GetIncludeDirectories returns the additional workspace roots of the current session
+func (c *ChatService) GetIncludeDirectories() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.includeDirs...)
+}
+
+// AddIncludeDirectory adds a workspace root to the current session
+func (c *ChatService) AddIncludeDirectory(dir string) error {
+	dir = expandHome(dir)
+	if !filepath.IsAbs(dir) {
+		base := c.GetWorkDir()
+		if base == "" {
+			base, _ = os.Getwd()
+		}
+		dir = filepath.Join(base, dir)
+	}
+	dir = filepath.Clean(dir)
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("cannot include directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("cannot include %s: not a directory", dir)
+	}
+
+	c.mu.Lock()
+	c.includeDirs = appendUnique(c.includeDirs, dir)
+	c.mu.Unlock()
+	return nil
+}
+
+// RemoveIncludeDirectory removes a workspace root from the current session
+func (c *ChatService) RemoveIncludeDirectory(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var kept []string
+	for _, d := range c.includeDirs {
+		if d != dir {
+			kept = append(kept, d)
+		}
+	}
+	c.includeDirs = kept
+}
+
+// workspace returns the file-tool workspace for the current session
+func (c *ChatService) workspace() *Workspace {
+	var allowed []string
+	if cfg := c.settings.GetConfig(); cfg != nil {
+		allowed = cfg.Security.AllowedDirectories
+	}
+	return NewWorkspace(c.GetWorkDir(), c.GetIncludeDirectories(), allowed)
+}
+
 // GetPlanMode returns whether plan mode is active
 func (c *ChatService) GetPlanMode() bool {
 	c.mu.Lock()
@@ -130,7 +272,7 @@ func (c *ChatService) SetPlanMode(enabled bool) {
 		} else if !enabled && prev {
 			c.history = append(c.history, api.Content{
 				Role:  "user",
-				Parts: []api.Part{{Text: "[SYSTEM: Plan Mode has been DEACTIVATED. All tools are now available. You may freely use write_file, replace, run_shell_command, and any other tools to make changes as requested.]"}},
+				Parts: []api.Part{{Text: "[SYSTEM: Plan Mode has been DEACTIVATED. All tools are now available. You may freely use write_file, replace, apply_patch, run_shell_command, and any other tools to make changes as requested.]"}},
 			})
 		}
 	}
@@ -184,7 +326,7 @@ func (c *ChatService) SendMessageWithFiles(text string, files []AttachedFile) er
 		displayContent += fmt.Sprintf(" [%d file(s) attached]", len(files))
 	}
 	userMsg := ChatMessage{
-		ID:        fmt.Sprintf("msg-%d", time.Now().UnixNano()),
+		ID:        newMessageID(),
 		Role:      "user",
 		Content:   displayContent,
 		Timestamp: time.Now(),
@@ -207,6 +349,25 @@ func (c *ChatService) SendMessageWithFiles(text string, files []AttachedFile) er
 	return nil
 }
 
+// ListCommands returns the custom slash commands provided by enabled extensions
+func (c *ChatService) ListCommands() []config.Command {
+	commands := []config.Command{}
+	for _, ext := range enabledExtensions(c.settings.GetConfig()) {
+		commands = append(commands, ext.Commands...)
+	}
+	return commands
+}
+
+// RunCommand expands a custom slash command with its arguments and sends it as a user message
+func (c *ChatService) RunCommand(name string, args string) error {
+	for _, cmd := range c.ListCommands() {
+		if cmd.Name == name {
+			return c.SendMessage(expandCommandPrompt(cmd.Prompt, strings.TrimSpace(args)))
+		}
+	}
+	return fmt.Errorf("unknown command: /%s", name)
+}
+
 // StopGeneration cancels the current streaming generation
 func (c *ChatService) StopGeneration() {
 	c.mu.Lock()
@@ -219,12 +380,17 @@ func (c *ChatService) StopGeneration() {
 
 // ClearHistory clears all conversation history and resets to default model
 func (c *ChatService) ClearHistory() {
+	c.endSession("clear")
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.messages = nil
 	c.history = nil
 	c.model = ""
+	c.thinking = nil
 	c.workDir = ""
+	c.includeDirs = nil
+	c.changes = nil
+	c.outputs.Clear()
 	runtime.EventsEmit(c.ctx, "chat:messages", []ChatMessage{})
 }
 
@@ -237,6 +403,12 @@ func (c *ChatService) GetMessages() []ChatMessage {
 	return result
 }
 
+// GetToolOutput returns the full text of a tool output that was shortened
+// for the model
+func (c *ChatService) GetToolOutput(handle string) (string, error) {
+	return c.outputs.Get(handle)
+}
+
 // AskUser sends questions to the frontend and blocks until the user responds
 func (c *ChatService) AskUser(ctx context.Context, questions []AskUserQuestion) (string, error) {
 	c.mu.Lock()
@@ -295,21 +467,71 @@ func (c *ChatService) streamResponse() {
 		return
 	}
 
-	c.doStream(ctx, client)
+	c.startSession(ctx)
+	c.mu.Lock()
+	c.stopHookActive = false
+	c.continuations, c.continuing = 0, false
+	c.mu.Unlock()
+
+	// Discover project tools when the settings changed; the error is
+	// reported to the model in the system prompt
+	cfg := c.settings.GetConfig()
+	ws := c.workspace()
+	c.tools.Discover(ctx, ws, cfg, sandboxOptions(c.settings, ws))
+
+	// Alternate model requests and tool calls until the model answers
+	// without calling a tool, at most model.maxToolRoundTrips times
+	maxRounds := defaultMaxToolRoundTrips
+	if cfg != nil && cfg.Model.MaxToolRoundTrips > 0 {
+		maxRounds = cfg.Model.MaxToolRoundTrips
+	}
+	loops := newLoopDetector(cfg == nil || !cfg.Model.DisableLoopDetection)
+	for rounds := 0; ; {
+		calls, next := c.doStream(ctx, client, loops)
+		if !next {
+			return
+		}
+		if len(calls) == 0 {
+			continue // the response was cut off, or a Stop hook asked the model to go on
+		}
+		if !c.handleToolCalls(ctx, calls) {
+			return
+		}
+		if ctx.Err() != nil {
+			return // stopped while the tools ran; the UI has already ended the turn
+		}
+		rounds++
+		if rounds >= maxRounds {
+			runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{
+				Type: "error",
+				Text: fmt.Sprintf("Stopped after %d tool round trips in one turn (model.maxToolRoundTrips). Send a message to continue.", rounds),
+			})
+			return
+		}
+	}
 }
 
-func (c *ChatService) doStream(ctx context.Context, client *api.Client) {
+// doStream sends one model request and streams the response. It returns the
+// tool calls to run, and whether the turn goes on (tool calls to answer, a
+// response cut off by the output token limit, or a Stop hook asking the model
+// to continue). When the turn ends it has already
+// emitted the done or error event.
+func (c *ChatService) doStream(ctx context.Context, client *api.Client, loops *loopDetector) ([]api.Part, bool) {
 	inPlanMode := c.GetPlanMode()
 
-	// Build tools: built-in + MCP (filtered in plan mode)
+	cfg := c.settings.GetConfig()
+
+	// Build tools: built-in + discovered + MCP (filtered in plan mode and by the tool policy)
 	var allDecls []api.FunctionDecl
+	discovered, discoveryErr := c.tools.Discovered()
 	if inPlanMode {
 		allDecls = PlanModeToolDeclarations()
 	} else {
-		allDecls = BuiltinToolDeclarations()
+		allDecls = append(BuiltinToolDeclarations(), discovered...)
 		mcpTools := c.mcp.GetAllTools()
 		allDecls = append(allDecls, mcpTools...)
 	}
+	allDecls, policyNotes := NewToolPolicy(cfg).FilterDeclarations(allDecls)
 	var tools []api.Tool
 	if len(allDecls) > 0 {
 		tools = []api.Tool{{FunctionDeclarations: allDecls}}
@@ -322,32 +544,68 @@ func (c *ChatService) doStream(ctx context.Context, client *api.Client) {
 	c.mu.Unlock()
 
 	// Build system instruction with environment context
-	systemPrompt := BuildSystemPrompt(c.GetWorkDir())
+	systemPrompt := BuildSystemPrompt(c.GetWorkDir(), c.GetIncludeDirectories(), enabledExtensions(cfg))
 	if inPlanMode {
-		systemPrompt += "\n\n## PLAN MODE ACTIVE\nYou are in Plan Mode. Only use read-only tools to explore the codebase and design an implementation plan. Do NOT make any changes to files. Present your plan to the user for approval before proceeding."
+		systemPrompt += "\n\n## PLAN MODE ACTIVE\nYou are in Plan Mode. Only use read-only tools (and read-only shell commands such as `git log` or `ls`) to explore the codebase and design an implementation plan. Do NOT make any changes to files. Present your plan to the user for approval before proceeding."
+	}
+	// BeforeModel hooks may block the request or add context
+	outcome := runHooks(ctx, cfg, c.workspace(), HookBeforeModel, c.GetModel(), map[string]interface{}{
+		"model":     c.GetModel(),
+		"plan_mode": inPlanMode,
+		"prompt":    lastUserText(historyCopy),
+	})
+	if outcome.Blocked || outcome.Stop {
+		runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{
+			Type: "error",
+			Text: "Blocked by BeforeModel hook: " + firstNonEmpty(outcome.Reason, outcome.StopReason),
+		})
+		return nil, false
+	}
+	c.mu.Lock()
+	hookContext := append(append([]string{}, c.hookContext...), outcome.Context...)
+	c.mu.Unlock()
+	if len(hookContext) > 0 {
+		systemPrompt += "\n\n## Hook Context\n" + strings.Join(hookContext, "\n\n")
+	}
+	if discoveryErr != nil {
+		systemPrompt += "\n\n## Project Tools\nProject-specific tools are unavailable because tool discovery failed. Tell the user if they ask for one of them.\n" + discoveryErr.Error()
+	}
+	if len(policyNotes) > 0 {
+		systemPrompt += "\n\n## Tool Restrictions\nThe user's settings restrict which tools you may use. Calls outside these rules are rejected; do not try to work around them.\n- " + strings.Join(policyNotes, "\n- ")
 	}
 	systemInstruction := &api.Content{
 		Parts: []api.Part{{Text: systemPrompt}},
 	}
 
+	// Old large tool results only go to the model as placeholders; the
+	// session keeps them in full
+	var aging config.ToolResultAgingConfig
+	if cfg != nil {
+		aging = cfg.Model.ToolResultAging
+	}
+	contents := ageToolResults(historyCopy, aging)
+
 	req := &api.GenerateRequest{
 		Model:   c.GetModel(),
 		Project: c.settings.GetProjectID(),
 		Request: api.InnerRequest{
-			Contents:          historyCopy,
+			Contents:          contents,
 			SystemInstruction: systemInstruction,
+			Config:            c.generationConfig(),
 			Tools:             tools,
 		},
 	}
 
 	// Start streaming
-	events, err := client.GenerateStream(ctx, req)
+	reqCtx, cancelReq := context.WithCancel(ctx)
+	defer cancelReq()
+	events, err := client.GenerateStream(reqCtx, req)
 	if err != nil {
 		runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{
 			Type: "error",
 			Text: fmt.Sprintf("Stream failed: %v", err),
 		})
-		return
+		return nil, false
 	}
 
 	runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{Type: "start"})
@@ -355,11 +613,17 @@ func (c *ChatService) doStream(ctx context.Context, client *api.Client) {
 	var fullText string
 	var thoughtText string
 	var pendingToolParts []api.Part
+	var loopReason string
+	var finish finishInfo
 
 	for event := range events {
 		switch event.Type {
 		case "thought":
 			thoughtText += event.Text
+			runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{
+				Type: "thought",
+				Text: event.Text,
+			})
 
 		case "content":
 			fullText += event.Text
@@ -367,17 +631,24 @@ func (c *ChatService) doStream(ctx context.Context, client *api.Client) {
 				Type: "content",
 				Text: event.Text,
 			})
+			loopReason = loops.content(event.Text)
 
 		case "tool_call":
+			loopReason = loops.toolCall(event.ToolCall.Name, event.ToolCall.Args)
 			pendingToolParts = append(pendingToolParts, api.Part{
 				FunctionCall:     event.ToolCall,
 				ThoughtSignature: event.ThoughtSignature,
 			})
 			argsJSON, _ := json.Marshal(event.ToolCall.Args)
+			var diffs []FileDiff
+			if !c.GetPlanMode() {
+				diffs = PreviewFileChanges(c.workspace(), event.ToolCall.Name, event.ToolCall.Args)
+			}
 			runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{
 				Type:     "tool_call",
 				ToolName: event.ToolCall.Name,
 				ToolArgs: string(argsJSON),
+				Diffs:    diffs,
 			})
 
 		case "error":
@@ -385,127 +656,442 @@ func (c *ChatService) doStream(ctx context.Context, client *api.Client) {
 				Type: "error",
 				Text: event.Error,
 			})
-			return
+			return nil, false
 
 		case "done":
-			// handled below
+			finish = newFinishInfo(event)
+		}
+
+		if loopReason != "" {
+			// Abort the request and let the stream goroutine finish
+			cancelReq()
+			for range events {
+			}
+			break
 		}
 	}
 
-	// Add model response to history
+	// A text answer cut off by the output token limit is continued, up to
+	// model.maxTokensContinuations times per turn
+	maxContinuations := defaultMaxTokensContinuations
+	if cfg != nil && cfg.Model.MaxTokensContinuations != 0 {
+		maxContinuations = cfg.Model.MaxTokensContinuations
+	}
+	c.mu.Lock()
+	cutOff := finish.Reason == "MAX_TOKENS" && fullText != "" && len(pendingToolParts) == 0 &&
+		loopReason == "" && ctx.Err() == nil && c.continuations < maxContinuations
+	if cutOff {
+		finish = finishInfo{}
+	}
+
+	c.addModelResponse(fullText, thoughtText, pendingToolParts, finish)
+	c.mu.Unlock()
+
+	if loopReason != "" {
+		// Keep what the model said, but not the tool calls that will not run
+		c.mu.Lock()
+		if n := len(c.history); len(pendingToolParts) > 0 && n > 0 && c.history[n-1].Role == "model" {
+			parts := c.history[n-1].Parts[:len(c.history[n-1].Parts)-len(pendingToolParts)]
+			if len(parts) > 0 {
+				c.history[n-1].Parts = parts
+			} else {
+				c.history = c.history[:n-1]
+			}
+		}
+		c.mu.Unlock()
+		runtime.EventsEmit(c.ctx, "chat:messages", c.GetMessages())
+		runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{
+			Type: "error",
+			Text: "Loop detected, stopping: " + loopReason,
+		})
+		return nil, false
+	}
+
+	// Tool calls are answered by the caller
+	if len(pendingToolParts) > 0 {
+		return pendingToolParts, true
+	}
+
+	if cutOff {
+		c.mu.Lock()
+		c.continuations++
+		c.continuing = true
+		c.history = append(c.history, api.Content{
+			Role:  "user",
+			Parts: []api.Part{{Text: continuePrompt}},
+		})
+		c.mu.Unlock()
+		runtime.EventsEmit(c.ctx, "chat:messages", c.GetMessages())
+		return nil, true
+	}
+
+	// Stop hooks may keep the model going once
 	c.mu.Lock()
-	if fullText != "" {
-		c.messages = append(c.messages, ChatMessage{
-			ID:        fmt.Sprintf("msg-%d", time.Now().UnixNano()),
-			Role:      "model",
-			Content:   fullText,
-			Timestamp: time.Now(),
+	active := c.stopHookActive
+	c.mu.Unlock()
+	stop := runHooks(ctx, cfg, c.workspace(), HookStop, "", map[string]interface{}{
+		"response":         fullText,
+		"stop_hook_active": active,
+	})
+	if stop.Blocked && !active && ctx.Err() == nil {
+		c.mu.Lock()
+		c.stopHookActive = true
+		c.history = append(c.history, api.Content{
+			Role:  "user",
+			Parts: []api.Part{{Text: "[SYSTEM: A Stop hook asked you to continue: " + stop.Reason + "]"}},
 		})
+		c.mu.Unlock()
+		runtime.EventsEmit(c.ctx, "chat:messages", c.GetMessages())
+		return nil, true
+	}
+
+	runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{Type: "done", FinishReason: finish.Reason})
+	runtime.EventsEmit(c.ctx, "chat:messages", c.GetMessages())
+	return nil, false
+}
+
+// lastUserText returns the text of the last user message in history
+func lastUserText(history []api.Content) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role != "user" {
+			continue
+		}
+		var texts []string
+		for _, p := range history[i].Parts {
+			if p.Text != "" {
+				texts = append(texts, p.Text)
+			}
+		}
+		if len(texts) > 0 {
+			return strings.Join(texts, "\n")
+		}
 	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// generationConfig returns the generation parameters of the session. Without
+// a thinking config of its own, the session asks for thoughts with the
+// model's default budget.
+func (c *ChatService) generationConfig() api.GenerationConfig {
+	thinking := c.GetThinkingConfig()
+	if thinking == nil {
+		thinking = &api.ThinkingConfig{IncludeThoughts: true}
+	}
+	return api.GenerationConfig{ThinkingConfig: thinking}
+}
+
+// addModelResponse adds a streamed response to the UI messages and the API
+// history. A continuation extends the message it continues; a response with
+// only thoughts, or without text that ended abnormally, still gets a message,
+// so the UI can show them or say why. c.mu must be held.
+func (c *ChatService) addModelResponse(text, thoughts string, toolParts []api.Part, finish finishInfo) {
+	if text != "" || thoughts != "" || (len(toolParts) == 0 && finish.abnormal()) {
+		if n := len(c.messages); c.continuing && n > 0 && c.messages[n-1].Role == "model" {
+			c.messages[n-1].Content += text
+			c.messages[n-1].Thoughts += thoughts
+			finish.apply(&c.messages[n-1])
+		} else {
+			msg := ChatMessage{
+				ID:        newMessageID(),
+				Role:      "model",
+				Content:   text,
+				Thoughts:  thoughts,
+				Timestamp: time.Now(),
+			}
+			finish.apply(&msg)
+			c.messages = append(c.messages, msg)
+		}
+	}
+	c.continuing = false
 
 	// Build model parts for API history (preserve thought + thoughtSignature)
 	var modelParts []api.Part
-	if thoughtText != "" {
-		modelParts = append(modelParts, api.Part{Thought: true, Text: thoughtText})
+	if thoughts != "" {
+		modelParts = append(modelParts, api.Part{Thought: true, Text: thoughts})
 	}
-	if fullText != "" {
-		modelParts = append(modelParts, api.Part{Text: fullText})
+	if text != "" {
+		modelParts = append(modelParts, api.Part{Text: text})
 	}
-	modelParts = append(modelParts, pendingToolParts...)
+	modelParts = append(modelParts, toolParts...)
 	if len(modelParts) > 0 {
 		c.history = append(c.history, api.Content{
 			Role:  "model",
 			Parts: modelParts,
 		})
 	}
-	c.mu.Unlock()
+}
 
-	// Handle tool calls if any
-	if len(pendingToolParts) > 0 {
-		c.handleToolCalls(ctx, client, pendingToolParts)
-		return
+// handleToolCalls runs the tool calls of a model response and adds their
+// results to the history. Consecutive read-only calls run concurrently; the
+// results keep the order of the calls. It returns false if a hook stopped
+// the turn.
+func (c *ChatService) handleToolCalls(ctx context.Context, toolCallParts []api.Part) bool {
+	cfg := c.settings.GetConfig()
+	batch := &toolBatch{cfg: cfg, policy: NewToolPolicy(cfg)}
+	if cfg != nil {
+		batch.dangerousCommands = cfg.Tools.DangerousCommands
 	}
 
-	runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{Type: "done"})
-	runtime.EventsEmit(c.ctx, "chat:messages", c.GetMessages())
-}
+	var calls []*api.FunctionCall
+	for _, part := range toolCallParts {
+		if part.FunctionCall != nil {
+			calls = append(calls, part.FunctionCall)
+		}
+	}
+
+	results := scheduleToolCalls(calls, func(tc *api.FunctionCall) []api.Part {
+		return c.runToolCall(ctx, batch, tc)
+	})
 
-func (c *ChatService) handleToolCalls(ctx context.Context, client *api.Client, toolCallParts []api.Part) {
 	var toolRespParts []api.Part
+	for _, parts := range results {
+		toolRespParts = append(toolRespParts, parts...)
+	}
 
-	for _, part := range toolCallParts {
-		tc := part.FunctionCall
-		if tc == nil {
-			continue
-		}
+	// Add tool results to API history
+	c.mu.Lock()
+	c.history = append(c.history, api.Content{
+		Role:  "user",
+		Parts: toolRespParts,
+	})
+	c.mu.Unlock()
 
-		// Add tool call message to UI
-		argsJSON, _ := json.Marshal(tc.Args)
-		c.mu.Lock()
-		c.messages = append(c.messages, ChatMessage{
-			ID:        fmt.Sprintf("msg-%d", time.Now().UnixNano()),
-			Role:      "tool_call",
-			Content:   tc.Name,
-			ToolName:  tc.Name,
-			ToolArgs:  string(argsJSON),
-			Timestamp: time.Now(),
+	runtime.EventsEmit(c.ctx, "chat:messages", c.GetMessages())
+
+	if stopReason := batch.stopped(); stopReason != "" {
+		runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{
+			Type: "error",
+			Text: "Stopped by hook: " + stopReason,
 		})
-		c.mu.Unlock()
+		return false
+	}
+	return true
+}
 
-		// Plan mode guard: reject non-read-only tools
-		var result string
-		var err error
-		if c.GetPlanMode() && !IsPlanModeTool(tc.Name) {
-			result = fmt.Sprintf("Error: tool %q is not allowed in Plan Mode. Only read-only tools are available.", tc.Name)
-		} else if tc.Name == "ask_user" {
-			result, err = c.execAskUser(ctx, tc.Args)
-		} else if IsBuiltinTool(tc.Name) {
-			result, err = ExecuteBuiltinTool(ctx, c.GetWorkDir(), tc.Name, tc.Args, c.settings)
-		} else {
-			result, err = c.mcp.CallTool(ctx, tc.Name, tc.Args)
+// scheduleToolCalls runs calls in order, except that consecutive calls to
+// parallelTools run concurrently, at most maxParallelToolCalls at a time.
+// results[i] holds what run returned for calls[i].
+func scheduleToolCalls(calls []*api.FunctionCall, run func(*api.FunctionCall) []api.Part) [][]api.Part {
+	results := make([][]api.Part, len(calls))
+	for i := 0; i < len(calls); {
+		j := i
+		for j < len(calls) && parallelTools[calls[j].Name] && !mutatingTools[calls[j].Name] {
+			j++
 		}
-		if err != nil {
-			result = fmt.Sprintf("Error: %v", err)
+		if j-i < 2 {
+			results[i] = run(calls[i])
+			i++
+			continue
 		}
 
-		runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{
-			Type:     "tool_result",
-			ToolName: tc.Name,
-			Text:     result,
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, maxParallelToolCalls)
+		for k := i; k < j; k++ {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(k int) {
+				defer func() { <-sem; wg.Done() }()
+				results[k] = run(calls[k])
+			}(k)
+		}
+		wg.Wait()
+		i = j
+	}
+	return results
+}
+
+// toolBatch is the state shared by the tool calls of one model response
+type toolBatch struct {
+	cfg               *config.Config
+	policy            *ToolPolicy
+	dangerousCommands string
+
+	mu         sync.Mutex
+	stopReason string // set when a hook ends the turn
+}
+
+func (b *toolBatch) stopped() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stopReason
+}
+
+func (b *toolBatch) stop(reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopReason == "" {
+		b.stopReason = reason
+	}
+}
+
+// runToolCall runs one tool call, emits its UI events and returns the parts
+// of its function response
+func (c *ChatService) runToolCall(ctx context.Context, batch *toolBatch, tc *api.FunctionCall) []api.Part {
+	cfg := batch.cfg
+	ws := c.workspace()
+
+	// BeforeTool hooks may block the call or rewrite its arguments
+	var hook hookOutcome
+	if batch.stopped() == "" {
+		hook = runHooks(ctx, cfg, ws, HookBeforeTool, tc.Name, map[string]interface{}{
+			"tool_name":  tc.Name,
+			"tool_input": tc.Args,
 		})
+		if hook.ToolInput != nil {
+			tc.Args = hook.ToolInput
+		}
+		if hook.Stop {
+			batch.stop(firstNonEmpty(hook.StopReason, "a BeforeTool hook stopped the turn"))
+		}
+	}
+	shellPolicy := batch.dangerousCommands
+	if hook.Approved {
+		shellPolicy = "allow"
+	}
 
-		// Add tool result to UI messages
-		c.mu.Lock()
-		c.messages = append(c.messages, ChatMessage{
-			ID:        fmt.Sprintf("msg-%d", time.Now().UnixNano()),
-			Role:      "tool_result",
-			Content:   result,
-			ToolName:  tc.Name,
-			Timestamp: time.Now(),
+	// Preview file changes against the current state of the disk; earlier
+	// calls in this batch may have changed it since the stream event
+	var changes []fileChange
+	var diffs, applied []FileDiff
+	if !c.GetPlanMode() {
+		changes = planFileChanges(ws, tc.Name, tc.Args)
+		diffs = fileDiffs(ws, changes)
+	}
+
+	// Add tool call message to UI; its ID links the result and the output to it
+	argsJSON, _ := json.Marshal(tc.Args)
+	callID := newMessageID()
+	c.mu.Lock()
+	c.messages = append(c.messages, ChatMessage{
+		ID:        callID,
+		Role:      "tool_call",
+		Content:   tc.Name,
+		ToolName:  tc.Name,
+		ToolArgs:  string(argsJSON),
+		CallID:    callID,
+		Diffs:     diffs,
+		Timestamp: time.Now(),
+	})
+	c.mu.Unlock()
+
+	// Plan mode guard: reject non-read-only tools
+	var result string
+	var inlineData *api.InlineData
+	var err error
+	ran := false
+	if hook.Blocked {
+		result = "Error: blocked by BeforeTool hook: " + hook.Reason
+	} else if stopReason := batch.stopped(); stopReason != "" {
+		result = "Error: not run because the turn was stopped: " + stopReason
+	} else if reason := PlanModeDenial(tc.Name, tc.Args); c.GetPlanMode() && reason != "" {
+		result = "Error: " + reason
+	} else if reason := batch.policy.Check(tc.Name, tc.Args); reason != "" {
+		result = "Error: " + reason
+	} else if reason := c.confirmShellCommand(ctx, tc.Name, tc.Args, shellPolicy); reason != "" {
+		result = "Error: " + reason
+	} else if tc.Name == "ask_user" {
+		result, err = c.execAskUser(ctx, tc.Args)
+	} else if c.tools.Has(tc.Name) {
+		// Shell commands and project tools may change any file; compare
+		// the workspace around them unless the command only reads
+		var before map[string]fileStamp
+		if mayChangeFiles(tc.Name, tc.Args) {
+			before = snapshotFiles(ws)
+		}
+		name := tc.Name
+		toolCtx := withProcessManager(ctx, c.procs)
+		toolCtx = withToolOutputStore(toolCtx, c.outputs)
+		toolCtx = withOutputStream(toolCtx, func(chunk string) {
+			runtime.EventsEmit(c.ctx, "chat:tool_output", ToolOutputEvent{CallID: callID, ToolName: name, Text: chunk})
 		})
-		c.mu.Unlock()
+		var tr ToolResult
+		ran = true
+		tr, err = c.tools.Execute(toolCtx, ws, tc.Name, tc.Args, c.settings)
+		result, inlineData = tr.Output, tr.InlineData
+		if err == nil {
+			applied = diffs
+			c.recordChanges(tc.Name, changes)
+		}
+		if before != nil {
+			c.recordShellChanges(tc.Name, before, snapshotFiles(ws))
+		}
+	} else {
+		ran = true
+		result, err = c.mcp.CallTool(ctx, tc.Name, tc.Args)
+	}
+	if err != nil {
+		result = fmt.Sprintf("Error: %v", err)
+	}
 
-		// Build function response for API
-		toolRespParts = append(toolRespParts, api.Part{
-			FunctionResp: &api.FunctionResp{
-				Name:     tc.Name,
-				Response: map[string]interface{}{"result": result},
-			},
+	// Store long output in full and send the model its start and end
+	var handle string
+	full := result
+	if tc.Name != "read_tool_output" {
+		result, handle = c.outputs.Spill(result)
+	}
+
+	// AfterTool hooks may add feedback or context to the result
+	if ran {
+		after := runHooks(ctx, cfg, ws, HookAfterTool, tc.Name, map[string]interface{}{
+			"tool_name":     tc.Name,
+			"tool_input":    tc.Args,
+			"tool_response": map[string]interface{}{"result": full, "error": err != nil},
 		})
+		if after.Blocked {
+			result += "\n\n[AfterTool hook] " + after.Reason
+		}
+		for _, text := range after.Context {
+			result += "\n\n" + text
+		}
+		if after.Stop {
+			batch.stop(firstNonEmpty(after.StopReason, "an AfterTool hook stopped the turn"))
+		}
 	}
 
-	// Add tool results to API history
+	runtime.EventsEmit(c.ctx, "chat:stream", ChatStreamEvent{
+		Type:     "tool_result",
+		ToolName: tc.Name,
+		Text:     result,
+		CallID:   callID,
+		Diffs:    applied,
+	})
+
+	// Add tool result to UI messages
 	c.mu.Lock()
-	c.history = append(c.history, api.Content{
-		Role:  "user",
-		Parts: toolRespParts,
+	c.messages = append(c.messages, ChatMessage{
+		ID:           newMessageID(),
+		Role:         "tool_result",
+		Content:      result,
+		ToolName:     tc.Name,
+		CallID:       callID,
+		Diffs:        applied,
+		OutputHandle: handle,
+		Timestamp:    time.Now(),
 	})
 	c.mu.Unlock()
-
 	runtime.EventsEmit(c.ctx, "chat:messages", c.GetMessages())
 
-	// Continue the conversation with tool results
-	c.doStream(ctx, client)
+	// Build function response for API
+	parts := []api.Part{{
+		FunctionResp: &api.FunctionResp{
+			Name:     tc.Name,
+			Response: map[string]interface{}{"result": result},
+		},
+	}}
+	// File content (image, PDF, audio) follows its function response
+	if inlineData != nil && err == nil {
+		parts = append(parts, api.Part{InlineData: inlineData})
+	}
+	return parts
 }
 
 func (c *ChatService) execAskUser(ctx context.Context, args map[string]interface{}) (string, error) {
@@ -549,6 +1135,33 @@ func (c *ChatService) execAskUser(ctx context.Context, args map[string]interface
 	return c.AskUser(ctx, questions)
 }
 
+// confirmShellCommand applies the dangerousCommands policy to a shell command
+// classified as dangerous: "block" rejects it, "allow" runs it and "ask" (the
+// default) asks the user. It returns why the command must not run, or "".
+func (c *ChatService) confirmShellCommand(ctx context.Context, name string, args map[string]interface{}, policy string) string {
+	if name != "run_shell_command" || policy == "allow" {
+		return ""
+	}
+	command, _ := args["command"].(string)
+	res := shellsafety.Classify(command)
+	if res.Level != shellsafety.Dangerous {
+		return ""
+	}
+	if policy == "block" {
+		return fmt.Sprintf("command blocked as dangerous (%s). Find a safer way or ask the user to run it.", res.Reason)
+	}
+
+	answer, err := c.AskUser(ctx, []AskUserQuestion{{
+		Question: fmt.Sprintf("Allow this dangerous command (%s)? %s", res.Reason, command),
+		Header:   "Dangerous command",
+		Type:     "yesno",
+	}})
+	if err != nil || !strings.HasSuffix(strings.TrimSpace(answer), ": Yes") {
+		return fmt.Sprintf("the user did not approve this dangerous command (%s)", res.Reason)
+	}
+	return ""
+}
+
 func stringVal(m map[string]interface{}, key string) string {
 	v, _ := m[key].(string)
 	return v
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/api"
+)
+
+func TestScheduleToolCalls(t *testing.T) {
+	var calls []*api.FunctionCall
+	for i := 0; i < 12; i++ {
+		calls = append(calls, &api.FunctionCall{Name: "read_file", Args: map[string]interface{}{"n": i}})
+	}
+	calls = append(calls,
+		&api.FunctionCall{Name: "write_file", Args: map[string]interface{}{"n": 12}},
+		&api.FunctionCall{Name: "apply_patch", Args: map[string]interface{}{"n": 13}},
+		&api.FunctionCall{Name: "grep_search", Args: map[string]interface{}{"n": 14}},
+		&api.FunctionCall{Name: "glob", Args: map[string]interface{}{"n": 15}},
+	)
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	results := scheduleToolCalls(calls, func(tc *api.FunctionCall) []api.Part {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		if !parallelTools[tc.Name] && active > 1 {
+			t.Errorf("%s ran alongside %d other calls", tc.Name, active-1)
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return []api.Part{{FunctionResp: &api.FunctionResp{Name: tc.Name, Response: tc.Args}}}
+	})
+
+	if maxActive < 2 || maxActive > maxParallelToolCalls {
+		t.Errorf("at most %d calls ran at once, want 2..%d", maxActive, maxParallelToolCalls)
+	}
+	if len(results) != len(calls) {
+		t.Fatalf("got %d results for %d calls", len(results), len(calls))
+	}
+	for i, parts := range results {
+		if len(parts) != 1 || parts[0].FunctionResp.Name != calls[i].Name || parts[0].FunctionResp.Response["n"] != i {
+			t.Errorf("result %d is out of order: %+v", i, parts)
+		}
+	}
+}
+
+func TestNewMessageIDUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := newMessageID()
+		if seen[id] {
+			t.Fatalf("duplicate message ID %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestThinkingConfigInRequest(t *testing.T) {
+	c := NewChatService(nil, nil)
+	if got := c.generationConfig().ThinkingConfig; got == nil || !got.IncludeThoughts || got.ThinkingBudget != nil {
+		t.Errorf("a new session does not ask for thoughts with the default budget: %+v", got)
+	}
+
+	budget := 1024
+	cfg := &api.ThinkingConfig{ThinkingBudget: &budget, IncludeThoughts: true}
+	c.SetThinkingConfig(cfg)
+	budget = 0 // the session keeps its own copy
+	got := c.generationConfig().ThinkingConfig
+	if got == nil || got.ThinkingBudget == nil || *got.ThinkingBudget != 1024 || !got.IncludeThoughts {
+		t.Errorf("unexpected thinking config in the request: %+v", got)
+	}
+
+	c.SetThinkingConfig(nil)
+	if got := c.generationConfig().ThinkingConfig; got == nil || got.ThinkingBudget != nil {
+		t.Errorf("clearing the thinking config left its budget in the request: %+v", got)
+	}
+}
+
+func TestAddModelResponseThoughts(t *testing.T) {
+	c := NewChatService(nil, nil)
+	c.messages = []ChatMessage{{ID: "u", Role: "user", Content: "explain"}}
+
+	c.addModelResponse("The answer starts", "Considering the question.", nil, finishInfo{})
+	if n := len(c.messages); n != 2 || c.messages[1].Thoughts != "Considering the question." {
+		t.Fatalf("thoughts not stored on the model message: %+v", c.messages)
+	}
+	if parts := c.history[0].Parts; len(parts) != 2 || !parts[0].Thought || parts[0].Text != "Considering the question." {
+		t.Errorf("thoughts not kept in the history: %+v", parts)
+	}
+
+	// A MAX_TOKENS continuation extends the same message
+	c.continuing = true
+	c.addModelResponse(" and ends here.", " Then the rest.", nil, finishInfo{Reason: "STOP"})
+	if n := len(c.messages); n != 2 {
+		t.Fatalf("continuation added a message: %+v", c.messages)
+	}
+	msg := c.messages[1]
+	if msg.Content != "The answer starts and ends here." || msg.Thoughts != "Considering the question. Then the rest." {
+		t.Errorf("continuation not appended: %+v", msg)
+	}
+	if c.continuing {
+		t.Error("continuing flag not reset")
+	}
+
+	// A response with only thoughts still gets a message
+	c.addModelResponse("", "Nothing to say.", nil, finishInfo{})
+	if n := len(c.messages); n != 3 || c.messages[2].Thoughts != "Nothing to say." {
+		t.Errorf("thought-only response has no message: %+v", c.messages)
+	}
+}
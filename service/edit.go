@@ -0,0 +1,456 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/diff"
+)
+
+// Match strategies reported by applyEdit, tried in this order
+const (
+	matchExact       = "exact"
+	matchLineEndings = "line endings"
+	matchWhitespace  = "whitespace"
+)
+
+// applyEdit replaces oldStr with newStr in content. If the exact text is not
+// found it retries with the file's line endings, then line by line ignoring
+// indentation and runs of whitespace, re-indenting newStr to match the file.
+// It returns the new content and the strategy that matched.
+func applyEdit(content, oldStr, newStr string, expected int) (string, string, error) {
+	if oldStr == "" {
+		return "", "", fmt.Errorf("old_string is required")
+	}
+	if expected < 1 {
+		expected = 1
+	}
+
+	if count := strings.Count(content, oldStr); count > 0 {
+		if err := checkCount(count, expected); err != nil {
+			return "", "", err
+		}
+		return strings.Replace(content, oldStr, newStr, expected), matchExact, nil
+	}
+
+	// Retry with the file's line endings
+	crlf := strings.Contains(content, "\r\n")
+	if crlf != strings.Contains(oldStr, "\r\n") {
+		o, n := toLF(oldStr), toLF(newStr)
+		if crlf {
+			o, n = toCRLF(o), toCRLF(n)
+		}
+		if count := strings.Count(content, o); count > 0 {
+			if err := checkCount(count, expected); err != nil {
+				return "", "", err
+			}
+			return strings.Replace(content, o, n, expected), matchLineEndings, nil
+		}
+	}
+
+	if result, ok, err := replaceFlexible(content, oldStr, newStr, expected, crlf); ok || err != nil {
+		return result, matchWhitespace, err
+	}
+
+	return "", "", fmt.Errorf("old_string not found in file (also tried normalized line endings and whitespace). Read the file again and copy the exact text to replace")
+}
+
+func checkCount(count, expected int) error {
+	if expected == 1 && count > 1 {
+		return fmt.Errorf("old_string matches %d locations. Include more context to uniquely identify the target, or set expected_replacements=%d", count, count)
+	}
+	if count != expected {
+		return fmt.Errorf("expected %d replacements but found %d matches", expected, count)
+	}
+	return nil
+}
+
+// replaceFlexible matches oldStr against whole lines of content, comparing
+// lines with leading/trailing whitespace trimmed and inner runs collapsed.
+func replaceFlexible(content, oldStr, newStr string, expected int, crlf bool) (string, bool, error) {
+	lines := strings.Split(toLF(content), "\n")
+	oldLines := strings.Split(strings.TrimSuffix(toLF(oldStr), "\n"), "\n")
+	newLines := strings.Split(strings.TrimSuffix(toLF(newStr), "\n"), "\n")
+	if len(oldLines) == 0 || normalizeSpace(strings.Join(oldLines, "")) == "" {
+		return "", false, nil
+	}
+
+	var starts []int
+	for i := 0; i+len(oldLines) <= len(lines); i++ {
+		if linesMatch(lines[i:i+len(oldLines)], oldLines) {
+			starts = append(starts, i)
+			i += len(oldLines) - 1
+		}
+	}
+	if len(starts) == 0 {
+		return "", false, nil
+	}
+	if err := checkCount(len(starts), expected); err != nil {
+		return "", true, err
+	}
+
+	var out []string
+	prev := 0
+	for _, start := range starts {
+		out = append(out, lines[prev:start]...)
+		out = append(out, reindent(newLines, leadingSpace(oldLines[0]), leadingSpace(lines[start]))...)
+		prev = start + len(oldLines)
+	}
+	out = append(out, lines[prev:]...)
+
+	result := strings.Join(out, "\n")
+	if crlf {
+		result = toCRLF(result)
+	}
+	return result, true, nil
+}
+
+func linesMatch(a, b []string) bool {
+	for i := range a {
+		if normalizeSpace(a[i]) != normalizeSpace(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+// reindent shifts lines that start with the indentation of old_string to the
+// indentation found in the file
+func reindent(lines []string, from, to string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		if strings.HasPrefix(l, from) {
+			l = to + l[len(from):]
+		}
+		out[i] = l
+	}
+	return out
+}
+
+func toLF(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+func toCRLF(s string) string {
+	return strings.ReplaceAll(toLF(s), "\n", "\r\n")
+}
+
+// strategyNote describes a non-exact match for the tool result
+func strategyNote(strategy string) string {
+	if strategy == matchExact {
+		return ""
+	}
+	return fmt.Sprintf(" (exact match failed; matched after normalizing %s)", strategy)
+}
+
+// fileChange is a planned change of one file, computed before anything is
+// written so that it can be previewed or abandoned
+type fileChange struct {
+	path    string // absolute destination
+	oldPath string // absolute source for renames
+	before  string // content before the change ("" for new files)
+	after   string
+	existed bool
+	delete  bool
+}
+
+func execReplace(ws *Workspace, args map[string]interface{}) (string, error) {
+	change, strategy, count, err := planReplace(ws, args)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(change.path, []byte(change.after), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return fmt.Sprintf("Successfully replaced %d occurrence(s) in %s%s", count, change.path, strategyNote(strategy)), nil
+}
+
+func planReplace(ws *Workspace, args map[string]interface{}) (fileChange, string, int, error) {
+	filePath, _ := args["file_path"].(string)
+	oldStr, _ := args["old_string"].(string)
+	newStr, _ := args["new_string"].(string)
+	if filePath == "" || oldStr == "" {
+		return fileChange{}, "", 0, fmt.Errorf("file_path and old_string are required")
+	}
+	filePath, err := ws.Resolve(filePath)
+	if err != nil {
+		return fileChange{}, "", 0, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fileChange{}, "", 0, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	expectedReplacements := 1
+	if e, ok := args["expected_replacements"].(float64); ok {
+		expectedReplacements = int(e)
+	}
+
+	newContent, strategy, err := applyEdit(string(data), oldStr, newStr, expectedReplacements)
+	if err != nil {
+		return fileChange{}, "", 0, err
+	}
+	return fileChange{path: filePath, before: string(data), after: newContent, existed: true}, strategy, expectedReplacements, nil
+}
+
+func execMultiEdit(ws *Workspace, args map[string]interface{}) (string, error) {
+	change, notes, err := planMultiEdit(ws, args)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(change.path, []byte(change.after), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	editsRaw, _ := args["edits"].([]interface{})
+	result := fmt.Sprintf("Successfully applied %d edit(s) to %s", len(editsRaw), change.path)
+	if len(notes) > 0 {
+		result += "\n" + strings.Join(notes, "\n")
+	}
+	return result, nil
+}
+
+// planMultiEdit applies every edit in memory; the file is only written if all succeed
+func planMultiEdit(ws *Workspace, args map[string]interface{}) (fileChange, []string, error) {
+	filePath, _ := args["file_path"].(string)
+	editsRaw, _ := args["edits"].([]interface{})
+	if filePath == "" || len(editsRaw) == 0 {
+		return fileChange{}, nil, fmt.Errorf("file_path and edits are required")
+	}
+	filePath, err := ws.Resolve(filePath)
+	if err != nil {
+		return fileChange{}, nil, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fileChange{}, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	content := string(data)
+	var notes []string
+	for i, raw := range editsRaw {
+		edit, ok := raw.(map[string]interface{})
+		if !ok {
+			return fileChange{}, nil, fmt.Errorf("edit %d of %d is not an object. No changes were written", i+1, len(editsRaw))
+		}
+		oldStr, _ := edit["old_string"].(string)
+		newStr, _ := edit["new_string"].(string)
+		expected := 1
+		if e, ok := edit["expected_replacements"].(float64); ok {
+			expected = int(e)
+		}
+
+		var strategy string
+		content, strategy, err = applyEdit(content, oldStr, newStr, expected)
+		if err != nil {
+			return fileChange{}, nil, fmt.Errorf("edit %d of %d failed: %v. No changes were written", i+1, len(editsRaw), err)
+		}
+		if note := strategyNote(strategy); note != "" {
+			notes = append(notes, fmt.Sprintf("edit %d%s", i+1, note))
+		}
+	}
+	return fileChange{path: filePath, before: string(data), after: content, existed: true}, notes, nil
+}
+
+func execApplyPatch(ws *Workspace, args map[string]interface{}) (string, error) {
+	changes, report, err := planApplyPatch(ws, args)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeFileChanges(changes); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Applied patch to %d file(s):\n%s", len(changes), strings.Join(report, "\n")), nil
+}
+
+// planApplyPatch applies every file of the patch in memory and returns the
+// changes with a per-file, per-hunk report. Nothing is planned unless all
+// hunks apply.
+func planApplyPatch(ws *Workspace, args map[string]interface{}) ([]fileChange, []string, error) {
+	patch, _ := args["patch"].(string)
+	if strings.TrimSpace(patch) == "" {
+		return nil, nil, fmt.Errorf("patch is required")
+	}
+	files, err := diff.Parse(patch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	var changes []fileChange
+	var report []string
+	pending := make(map[string]*string) // content after earlier changes in this patch
+	failed := false
+
+	readCurrent := func(path string) (string, bool) {
+		if c, ok := pending[path]; ok {
+			if c == nil {
+				return "", false
+			}
+			return *c, true
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+
+	for _, fp := range files {
+		label, change, results, err := planFilePatch(ws, fp, readCurrent)
+		report = append(report, label)
+		for i, r := range results {
+			report = append(report, fmt.Sprintf("  hunk %d: %s", i+1, r))
+		}
+		if err != nil {
+			report = append(report, "  error: "+err.Error())
+			failed = true
+			continue
+		}
+		changes = append(changes, change)
+		if change.delete {
+			pending[change.path] = nil
+		} else {
+			content := change.after
+			pending[change.path] = &content
+		}
+		if change.oldPath != "" {
+			pending[change.oldPath] = nil
+		}
+	}
+
+	if failed {
+		return nil, report, fmt.Errorf("patch failed. No changes were written.\n%s", strings.Join(report, "\n"))
+	}
+	return changes, report, nil
+}
+
+// planFilePatch computes the change for one file. It returns a status line
+// (git-style A/D/M/R), the planned change and per-hunk results.
+func planFilePatch(ws *Workspace, fp diff.FilePatch, readCurrent func(string) (string, bool)) (string, fileChange, []diff.HunkResult, error) {
+	var change fileChange
+	var label string
+	var oldPath string
+	var err error
+
+	switch {
+	case fp.IsNew():
+		label = "A " + fp.NewPath
+	case fp.IsDelete():
+		label = "D " + fp.OldPath
+	case fp.IsRename():
+		label = "R " + fp.OldPath + " -> " + fp.NewPath
+	default:
+		label = "M " + fp.NewPath
+	}
+
+	if !fp.IsNew() {
+		if oldPath, err = ws.Resolve(fp.OldPath); err != nil {
+			return label, change, nil, err
+		}
+	}
+	if !fp.IsDelete() {
+		if change.path, err = ws.Resolve(fp.NewPath); err != nil {
+			return label, change, nil, err
+		}
+	}
+
+	if fp.IsNew() {
+		if _, exists := readCurrent(change.path); exists {
+			return label, change, nil, fmt.Errorf("%s already exists", fp.NewPath)
+		}
+	} else {
+		var ok bool
+		if change.before, ok = readCurrent(oldPath); !ok {
+			return label, change, nil, fmt.Errorf("%s does not exist", fp.OldPath)
+		}
+		change.existed = true
+	}
+	if fp.IsRename() {
+		if _, exists := readCurrent(change.path); exists {
+			return label, change, nil, fmt.Errorf("cannot rename onto existing file %s", fp.NewPath)
+		}
+		change.oldPath = oldPath
+	}
+
+	newContent, results, err := diff.Apply(change.before, fp.Hunks)
+	if err != nil {
+		return label, change, results, err
+	}
+
+	if fp.IsDelete() {
+		if strings.TrimSpace(newContent) != "" && len(fp.Hunks) > 0 {
+			return label, change, results, fmt.Errorf("deletion patch does not remove all content of %s", fp.OldPath)
+		}
+		change.path, change.delete = oldPath, true
+		return label, change, results, nil
+	}
+	change.after = newContent
+	return label, change, results, nil
+}
+
+// writeFileChanges performs the planned changes, restoring the original files if any write fails
+func writeFileChanges(changes []fileChange) error {
+	type backup struct {
+		path    string
+		content []byte
+		existed bool
+	}
+	var backups []backup
+	save := func(path string) {
+		data, err := os.ReadFile(path)
+		backups = append(backups, backup{path: path, content: data, existed: err == nil})
+	}
+	rollback := func() {
+		for i := len(backups) - 1; i >= 0; i-- {
+			b := backups[i]
+			if b.existed {
+				os.MkdirAll(filepath.Dir(b.path), 0o755)
+				os.WriteFile(b.path, b.content, 0o644)
+			} else {
+				os.Remove(b.path)
+			}
+		}
+	}
+
+	for _, change := range changes {
+		save(change.path)
+		if change.oldPath != "" {
+			save(change.oldPath)
+		}
+
+		var err error
+		switch {
+		case change.delete:
+			err = os.Remove(change.path)
+		default:
+			if err = os.MkdirAll(filepath.Dir(change.path), 0o755); err == nil {
+				err = os.WriteFile(change.path, []byte(change.after), 0o644)
+			}
+			if err == nil && change.oldPath != "" {
+				err = os.Remove(change.oldPath)
+			}
+		}
+		if err != nil {
+			rollback()
+			return fmt.Errorf("failed to write %s: %w (all changes were rolled back)", change.path, err)
+		}
+	}
+	return nil
+}
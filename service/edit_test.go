@@ -0,0 +1,123 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		old, new string
+		want     string
+		strategy string
+	}{
+		{"exact", "a\nb\nc\n", "b", "B", "a\nB\nc\n", matchExact},
+		{"crlf file", "a\r\nb\r\nc\r\n", "a\nb", "x\ny", "x\r\ny\r\nc\r\n", matchLineEndings},
+		{"indentation", "func f() {\n\t\tif x {\n\t\t\treturn\n\t\t}\n}\n",
+			"if x {\n\treturn\n}", "if y {\n\treturn nil\n}",
+			"func f() {\n\t\tif y {\n\t\t\treturn nil\n\t\t}\n}\n", matchWhitespace},
+		{"inner spaces", "x :=  1 +   2\n", "x := 1 + 2", "x := 3", "x := 3\n", matchWhitespace},
+	}
+	for _, tt := range tests {
+		got, strategy, err := applyEdit(tt.content, tt.old, tt.new, 1)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want || strategy != tt.strategy {
+			t.Errorf("%s: got %q (%s), want %q (%s)", tt.name, got, strategy, tt.want, tt.strategy)
+		}
+	}
+
+	if _, _, err := applyEdit("a\na\n", "a", "b", 1); err == nil || !strings.Contains(err.Error(), "2 locations") {
+		t.Errorf("expected ambiguity error, got %v", err)
+	}
+	if _, _, err := applyEdit("a\n", "missing", "b", 1); err == nil {
+		t.Error("expected not found error")
+	}
+}
+
+func TestMultiEditIsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f.txt")
+	os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644)
+	ws := NewWorkspace(dir, nil, nil)
+
+	edit := func(old, new string) interface{} {
+		return map[string]interface{}{"old_string": old, "new_string": new}
+	}
+
+	_, err := execMultiEdit(ws, map[string]interface{}{
+		"file_path": "f.txt",
+		"edits":     []interface{}{edit("one", "1"), edit("missing", "x")},
+	})
+	if err == nil || !strings.Contains(err.Error(), "edit 2 of 2") {
+		t.Fatalf("expected second edit to fail, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("file modified by a failed multi_edit: %q", data)
+	}
+
+	// Later edits see the result of earlier ones
+	if _, err := execMultiEdit(ws, map[string]interface{}{
+		"file_path": "f.txt",
+		"edits":     []interface{}{edit("one", "1"), edit("1\ntwo", "1\n2")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "1\n2\nthree\n" {
+		t.Errorf("unexpected content: %q", data)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc old() {}\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "gone.txt"), []byte("bye\n"), 0o644)
+	ws := NewWorkspace(dir, nil, nil)
+
+	patch := `--- a/main.go
++++ b/main.go
+@@ -3 +3 @@
+-func old() {}
++func renamed() {}
+--- /dev/null
++++ b/sub/new.txt
+@@ -0,0 +1 @@
++hello
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`
+	result, err := execApplyPatch(ws, map[string]interface{}{"patch": patch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "M main.go") || !strings.Contains(result, "A sub/new.txt") || !strings.Contains(result, "D gone.txt") {
+		t.Errorf("unexpected report:\n%s", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.go")); string(data) != "package main\n\nfunc renamed() {}\n" {
+		t.Errorf("main.go not patched: %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "sub", "new.txt")); string(data) != "hello\n" {
+		t.Errorf("new file not created: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.txt")); !os.IsNotExist(err) {
+		t.Error("gone.txt not deleted")
+	}
+
+	// A failing hunk in one file leaves every file untouched
+	bad := "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n--- a/sub/new.txt\n+++ b/sub/new.txt\n@@ -1 +1 @@\n-missing\n+x\n"
+	_, err = execApplyPatch(ws, map[string]interface{}{"patch": bad})
+	if err == nil || !strings.Contains(err.Error(), "No changes were written") {
+		t.Fatalf("expected atomic failure, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.go")); !strings.HasPrefix(string(data), "package main") {
+		t.Errorf("main.go changed by a failed patch: %q", data)
+	}
+}
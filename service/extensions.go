@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// ExtensionService exposes installed Gemini CLI extensions to the frontend
+type ExtensionService struct {
+	ctx      context.Context
+	settings *SettingsService
+	mcp      *MCPManager
+}
+
+// NewExtensionService creates a new extension service
+func NewExtensionService(settings *SettingsService, mcp *MCPManager) *ExtensionService {
+	return &ExtensionService{
+		settings: settings,
+		mcp:      mcp,
+	}
+}
+
+// SetContext sets the Wails runtime context
+func (e *ExtensionService) SetContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+// ListExtensions returns all installed extensions with their enablement for the current directory
+func (e *ExtensionService) ListExtensions() []config.Extension {
+	cfg := e.settings.GetConfig()
+	if cfg == nil {
+		return []config.Extension{}
+	}
+	result := make([]config.Extension, len(cfg.Extensions))
+	copy(result, cfg.Extensions)
+	return result
+}
+
+// SetExtensionEnabled enables or disables an extension for the current directory
+// (and its subdirectories), then reloads config and syncs the extension's MCP servers.
+func (e *ExtensionService) SetExtensionEnabled(name string, enabled bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to resolve current directory: %w", err)
+	}
+	if err := config.SetExtensionEnabled(name, cwd, enabled); err != nil {
+		return fmt.Errorf("failed to update extension enablement: %w", err)
+	}
+	if !enabled {
+		e.disconnectServers(name)
+	}
+	if err := e.reload(); err != nil {
+		return err
+	}
+	if enabled {
+		e.connectServers(name)
+	}
+	return nil
+}
+
+// InstallExtension installs an extension from a local directory or git URL
+func (e *ExtensionService) InstallExtension(source string) (*config.Extension, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return nil, fmt.Errorf("source is required")
+	}
+	ext, err := config.InstallExtension(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.reload(); err != nil {
+		return nil, err
+	}
+	e.connectServers(ext.Name)
+	return ext, nil
+}
+
+// UpdateExtension updates a git-installed extension and returns a short status message
+func (e *ExtensionService) UpdateExtension(name string) (string, error) {
+	e.disconnectServers(name)
+	oldVersion, newVersion, err := config.UpdateExtension(name)
+	if reloadErr := e.reload(); err == nil {
+		err = reloadErr
+	}
+	e.connectServers(name)
+	if err != nil {
+		return "", err
+	}
+	if oldVersion == newVersion {
+		return fmt.Sprintf("%s updated (version %s)", name, newVersion), nil
+	}
+	return fmt.Sprintf("%s updated from %s to %s", name, oldVersion, newVersion), nil
+}
+
+// UninstallExtension removes an installed extension
+func (e *ExtensionService) UninstallExtension(name string) error {
+	e.disconnectServers(name)
+	if err := config.UninstallExtension(name); err != nil {
+		return err
+	}
+	return e.reload()
+}
+
+// reload re-reads config so extension changes take effect and notifies the frontend
+func (e *ExtensionService) reload() error {
+	if err := e.settings.ReloadConfig(); err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	runtime.EventsEmit(e.ctx, "extensions:updated", e.ListExtensions())
+	return nil
+}
+
+// connectServers connects the MCP servers of an enabled extension in the background
+func (e *ExtensionService) connectServers(name string) {
+	for _, ext := range e.ListExtensions() {
+		if ext.Name != name || !ext.Enabled {
+			continue
+		}
+		for _, server := range e.ownedServers(ext) {
+			go func(server string) {
+				if err := e.mcp.ConnectServer(server); err != nil {
+					fmt.Printf("MCP: failed to connect %q: %v\n", server, err)
+				}
+			}(server)
+		}
+	}
+}
+
+// disconnectServers disconnects the MCP servers of an extension
+func (e *ExtensionService) disconnectServers(name string) {
+	for _, ext := range e.ListExtensions() {
+		if ext.Name != name {
+			continue
+		}
+		for _, server := range e.ownedServers(ext) {
+			_ = e.mcp.DisconnectServer(server)
+		}
+	}
+}
+
+// ownedServers returns the MCP servers of ext that the extension provides.
+// A server configured in settings under the same name belongs to the user
+// and is left alone.
+func (e *ExtensionService) ownedServers(ext config.Extension) []string {
+	cfg := e.settings.GetConfig()
+	if cfg == nil {
+		return nil
+	}
+	var owned []string
+	for _, server := range ext.MCPServers {
+		if serverCfg, ok := cfg.MCPServers[server]; ok && serverCfg.Extension == ext.Name {
+			owned = append(owned, server)
+		}
+	}
+	return owned
+}
+
+// enabledExtensions returns the enabled extensions from the current config
+func enabledExtensions(cfg *config.Config) []config.Extension {
+	if cfg == nil {
+		return nil
+	}
+	var result []config.Extension
+	for _, ext := range cfg.Extensions {
+		if ext.Enabled {
+			result = append(result, ext)
+		}
+	}
+	return result
+}
+
+// expandCommandPrompt substitutes {{args}} in a custom command prompt.
+// If the prompt has no placeholder, the arguments are appended after a blank line.
+func expandCommandPrompt(prompt, args string) string {
+	if strings.Contains(prompt, "{{args}}") {
+		return strings.ReplaceAll(prompt, "{{args}}", args)
+	}
+	if args == "" {
+		return prompt
+	}
+	return prompt + "\n\n" + args
+}
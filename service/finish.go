@@ -0,0 +1,61 @@
+package service
+
+import (
+	"github.com/tomohiro-owada/gmn-gui/internal/api"
+)
+
+// defaultMaxTokensContinuations bounds how often a response cut off by the
+// output token limit is continued in one turn
+const defaultMaxTokensContinuations = 3
+
+// continuePrompt asks the model to go on after a response hit MAX_TOKENS
+const continuePrompt = "[SYSTEM: Your previous response was cut off because it reached the maximum output length. Continue exactly where it stopped, without repeating or summarizing what you already wrote.]"
+
+// finishInfo is why a response ended, from the done event of its stream
+type finishInfo struct {
+	Reason      string   // finish reason of the candidate, e.g. "STOP", "MAX_TOKENS", "SAFETY"
+	Message     string   // explanation the API gave with the finish reason
+	BlockReason string   // why the prompt was blocked, if it was
+	Categories  []string // harm categories that blocked the prompt or response
+}
+
+func newFinishInfo(done api.StreamEvent) finishInfo {
+	f := finishInfo{Reason: done.FinishReason, Message: done.FinishMessage}
+	ratings := done.SafetyRatings
+	if pf := done.PromptFeedback; pf != nil && pf.BlockReason != "" {
+		f.BlockReason = pf.BlockReason
+		f.Message = firstNonEmpty(pf.BlockReasonMessage, f.Message)
+		ratings = pf.SafetyRatings
+	}
+	for _, r := range ratings {
+		if r.Blocked || ((f.Reason == "SAFETY" || f.BlockReason != "") && (r.Probability == "HIGH" || r.Probability == "MEDIUM")) {
+			f.Categories = append(f.Categories, r.Category)
+		}
+	}
+	return f
+}
+
+// abnormal reports whether the response did not end the usual way and the
+// user should be told why
+func (f finishInfo) abnormal() bool {
+	if f.BlockReason != "" {
+		return true
+	}
+	switch f.Reason {
+	case "", "STOP", "FINISH_REASON_UNSPECIFIED":
+		return false
+	}
+	return true
+}
+
+// apply records an abnormal finish on the model message shown for the response
+func (f finishInfo) apply(msg *ChatMessage) {
+	if !f.abnormal() {
+		msg.FinishReason, msg.BlockReason, msg.FinishMessage, msg.SafetyCategories = "", "", "", nil
+		return
+	}
+	msg.FinishReason = f.Reason
+	msg.BlockReason = f.BlockReason
+	msg.FinishMessage = f.Message
+	msg.SafetyCategories = f.Categories
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/api"
+)
+
+func TestNewFinishInfo(t *testing.T) {
+	if f := newFinishInfo(api.StreamEvent{Type: "done", FinishReason: "STOP"}); f.abnormal() {
+		t.Errorf("STOP is abnormal: %+v", f)
+	}
+	if f := newFinishInfo(api.StreamEvent{Type: "done", FinishReason: "MAX_TOKENS"}); !f.abnormal() || f.Categories != nil {
+		t.Errorf("MAX_TOKENS: %+v", f)
+	}
+
+	f := newFinishInfo(api.StreamEvent{
+		Type:         "done",
+		FinishReason: "SAFETY",
+		SafetyRatings: []api.SafetyRating{
+			{Category: "HARM_CATEGORY_HARASSMENT", Probability: "NEGLIGIBLE"},
+			{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Probability: "HIGH"},
+		},
+	})
+	if !f.abnormal() || !reflect.DeepEqual(f.Categories, []string{"HARM_CATEGORY_DANGEROUS_CONTENT"}) {
+		t.Errorf("SAFETY: %+v", f)
+	}
+
+	f = newFinishInfo(api.StreamEvent{
+		Type: "done",
+		PromptFeedback: &api.PromptFeedback{
+			BlockReason:   "PROHIBITED_CONTENT",
+			SafetyRatings: []api.SafetyRating{{Category: "HARM_CATEGORY_HATE_SPEECH", Probability: "LOW", Blocked: true}},
+		},
+	})
+	var msg ChatMessage
+	f.apply(&msg)
+	if msg.BlockReason != "PROHIBITED_CONTENT" || !reflect.DeepEqual(msg.SafetyCategories, []string{"HARM_CATEGORY_HATE_SPEECH"}) {
+		t.Errorf("blocked prompt: %+v", msg)
+	}
+}
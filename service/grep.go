@@ -0,0 +1,471 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/envutil"
+	"github.com/tomohiro-owada/gmn-gui/internal/glob"
+)
+
+// grep_search output modes
+const (
+	grepModeContent = "content"
+	grepModeFiles   = "files_with_matches"
+	grepModeCount   = "count"
+)
+
+const (
+	defaultGrepLimit = 500     // output lines per page
+	maxGrepFileSize  = 5 << 20 // larger files are skipped by the Go backend
+)
+
+// grepOptions holds the parsed grep_search arguments
+type grepOptions struct {
+	pattern       string
+	include       string
+	caseSensitive bool
+	multiline     bool
+	before        int
+	after         int
+	mode          string
+	offset        int
+	limit         int
+}
+
+// grepLine is a matching or context line of a file
+type grepLine struct {
+	num   int
+	text  string
+	match bool
+}
+
+// grepFile holds the results for one file, lines in ascending order
+type grepFile struct {
+	path    string
+	matches int
+	lines   []grepLine
+}
+
+// grepCollector formats results as they arrive and stops once a page is full
+type grepCollector struct {
+	opts     grepOptions
+	display  func(path string) string
+	lines    []string
+	lastPath string
+	lastLine int
+}
+
+// add formats one file's results; it returns false when enough lines were collected
+func (g *grepCollector) add(f grepFile) bool {
+	switch g.opts.mode {
+	case grepModeFiles:
+		g.lines = append(g.lines, g.display(f.path))
+	case grepModeCount:
+		g.lines = append(g.lines, fmt.Sprintf("%s:%d", g.display(f.path), f.matches))
+	default:
+		name := g.display(f.path)
+		hasContext := g.opts.before > 0 || g.opts.after > 0
+		for _, l := range f.lines {
+			// Separate non-contiguous blocks like grep/rg do
+			if hasContext && len(g.lines) > 0 && (f.path != g.lastPath || l.num > g.lastLine+1) {
+				g.lines = append(g.lines, "--")
+			}
+			sep := "-"
+			if l.match {
+				sep = ":"
+			}
+			g.lines = append(g.lines, name+sep+strconv.Itoa(l.num)+sep+l.text)
+			g.lastPath, g.lastLine = f.path, l.num
+		}
+	}
+	return len(g.lines) <= g.opts.offset+g.opts.limit
+}
+
+func (g *grepCollector) result() string {
+	if len(g.lines) == 0 {
+		return "(no matches found)"
+	}
+	if g.opts.offset >= len(g.lines) {
+		return fmt.Sprintf("(no results at offset %d)", g.opts.offset)
+	}
+
+	end := g.opts.offset + g.opts.limit
+	more := len(g.lines) > end
+	if !more {
+		end = len(g.lines)
+	}
+	result := strings.Join(g.lines[g.opts.offset:end], "\n")
+	if more {
+		result += fmt.Sprintf("\n... (more results available; call again with offset=%d)", end)
+	}
+	return result
+}
+
+func parseGrepOptions(args map[string]interface{}) (grepOptions, error) {
+	opts := grepOptions{
+		caseSensitive: true,
+		mode:          grepModeContent,
+		limit:         defaultGrepLimit,
+	}
+	opts.pattern, _ = args["pattern"].(string)
+	if opts.pattern == "" {
+		return opts, fmt.Errorf("pattern is required")
+	}
+	opts.include, _ = args["include"].(string)
+	if v, ok := args["case_sensitive"].(bool); ok {
+		opts.caseSensitive = v
+	}
+	opts.multiline, _ = args["multiline"].(bool)
+
+	intArg := func(name string) int {
+		if v, ok := args[name].(float64); ok && v > 0 {
+			return int(v)
+		}
+		return 0
+	}
+	if c := intArg("context"); c > 0 {
+		opts.before, opts.after = c, c
+	}
+	if b := intArg("before"); b > 0 {
+		opts.before = b
+	}
+	if a := intArg("after"); a > 0 {
+		opts.after = a
+	}
+	opts.offset = intArg("offset")
+	if l := intArg("limit"); l > 0 {
+		opts.limit = l
+	}
+
+	if mode, _ := args["output_mode"].(string); mode != "" {
+		switch mode {
+		case grepModeContent, grepModeFiles, grepModeCount:
+			opts.mode = mode
+		default:
+			return opts, fmt.Errorf("invalid output_mode %q: use content, files_with_matches or count", mode)
+		}
+	}
+	return opts, nil
+}
+
+// compile builds the Go regexp for the options (also used to validate patterns for rg)
+func (o grepOptions) compile() (*regexp.Regexp, error) {
+	flags := ""
+	if !o.caseSensitive {
+		flags += "i"
+	}
+	if o.multiline {
+		flags += "ms"
+	}
+	pattern := o.pattern
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	return re, nil
+}
+
+func execGrepSearch(ctx context.Context, ws *Workspace, args map[string]interface{}) (string, error) {
+	opts, err := parseGrepOptions(args)
+	if err != nil {
+		return "", err
+	}
+	re, err := opts.compile()
+	if err != nil {
+		return "", err
+	}
+
+	roots, err := ws.SearchRoots(args)
+	if err != nil {
+		return "", err
+	}
+
+	// Use ripgrep when available; it is much faster on large trees
+	rg, rgErr := envutil.LookPath("rg")
+
+	c := &grepCollector{opts: opts}
+	for _, dir := range roots {
+		root := dir
+		c.display = func(path string) string { return displayPath(roots, root, path) }
+
+		var more bool
+		if rgErr == nil {
+			more, err = grepWithRipgrep(ctx, rg, ws, dir, opts, c)
+		} else {
+			more, err = grepWithGo(ctx, ws, dir, re, opts, c)
+		}
+		if err != nil {
+			return "", fmt.Errorf("search failed: %w", err)
+		}
+		if !more {
+			break
+		}
+	}
+
+	return c.result(), nil
+}
+
+// grepWithGo searches dir with the Go regexp engine. It returns false once the
+// collector is full.
+func grepWithGo(ctx context.Context, ws *Workspace, dir string, re *regexp.Regexp, opts grepOptions, c *grepCollector) (bool, error) {
+	more := true
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Skip ignored directories and files
+		if info.IsDir() {
+			if path != dir && ws.Ignored(path, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ws.Ignored(path, false) || info.Size() > maxGrepFileSize {
+			return nil
+		}
+
+		// Check file pattern filter
+		if opts.include != "" && !glob.Match(opts.include, info.Name()) && !glob.Match(opts.include, ws.RelPath(path)) {
+			return nil
+		}
+
+		// Don't follow symlinks that lead out of the workspace
+		if info.Mode()&os.ModeSymlink != 0 && !ws.Contains(resolveSymlinks(path)) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || isBinaryContent(data) {
+			return nil
+		}
+
+		f := grepContent(path, string(data), re, opts)
+		if f.matches == 0 {
+			return nil
+		}
+		if !c.add(f) {
+			more = false
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil && err != filepath.SkipAll {
+		return false, err
+	}
+	return more, nil
+}
+
+// grepContent finds the matching lines of one file and adds the requested context
+func grepContent(path, content string, re *regexp.Regexp, opts grepOptions) grepFile {
+	f := grepFile{path: path}
+	lines := strings.Split(content, "\n")
+	if strings.HasSuffix(content, "\n") {
+		lines = lines[:len(lines)-1]
+	}
+
+	matched := make([]bool, len(lines))
+	if opts.multiline {
+		// Map byte offsets of each match to the lines it spans
+		starts := make([]int, len(lines))
+		offset := 0
+		for i, l := range lines {
+			starts[i] = offset
+			offset += len(l) + 1
+		}
+		lineAt := func(off int) int {
+			return sort.Search(len(starts), func(i int) bool { return starts[i] > off }) - 1
+		}
+		for _, loc := range re.FindAllStringIndex(content, -1) {
+			end := loc[1]
+			if end > loc[0] {
+				end-- // last byte of the match
+			}
+			first, last := lineAt(loc[0]), lineAt(end)
+			if first < 0 || first >= len(lines) {
+				continue
+			}
+			if last >= len(lines) {
+				last = len(lines) - 1
+			}
+			for i := first; i <= last; i++ {
+				matched[i] = true
+			}
+			f.matches++
+		}
+	} else {
+		for i, l := range lines {
+			if re.MatchString(strings.TrimSuffix(l, "\r")) {
+				matched[i] = true
+				f.matches++
+			}
+		}
+	}
+	if f.matches == 0 {
+		return f
+	}
+
+	include := make([]bool, len(lines))
+	for i, m := range matched {
+		if !m {
+			continue
+		}
+		for j := max(0, i-opts.before); j <= min(len(lines)-1, i+opts.after); j++ {
+			include[j] = true
+		}
+	}
+	for i, inc := range include {
+		if inc {
+			f.lines = append(f.lines, grepLine{num: i + 1, text: strings.TrimSuffix(lines[i], "\r"), match: matched[i]})
+		}
+	}
+	return f
+}
+
+// isBinaryContent reports whether data looks binary (contains a NUL byte near the start)
+func isBinaryContent(data []byte) bool {
+	n := len(data)
+	if n > 8000 {
+		n = 8000
+	}
+	for _, b := range data[:n] {
+		if b == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// rgEvent is one line of `rg --json` output
+type rgEvent struct {
+	Type string `json:"type"` // "begin" | "match" | "context" | "end" | "summary"
+	Data struct {
+		Path       rgText `json:"path"`
+		Lines      rgText `json:"lines"`
+		LineNumber int    `json:"line_number"`
+	} `json:"data"`
+}
+
+type rgText struct {
+	Text string `json:"text"`
+}
+
+// grepWithRipgrep searches dir with rg. Ignore rules are applied by rg and
+// re-checked with the workspace matcher, which also honors .geminiignore files.
+// It returns false once the collector is full.
+func grepWithRipgrep(ctx context.Context, rg string, ws *Workspace, dir string, opts grepOptions, c *grepCollector) (bool, error) {
+	args := []string{
+		"--json", "--hidden", "--no-require-git", "--sort", "path",
+		"--max-filesize", strconv.Itoa(maxGrepFileSize),
+		"-g", "!.git", "-g", "!node_modules/",
+	}
+	if !opts.caseSensitive {
+		args = append(args, "-i")
+	}
+	if opts.multiline {
+		args = append(args, "-U", "--multiline-dotall")
+	}
+	if opts.before > 0 {
+		args = append(args, "-B", strconv.Itoa(opts.before))
+	}
+	if opts.after > 0 {
+		args = append(args, "-A", strconv.Itoa(opts.after))
+	}
+	if opts.include != "" {
+		args = append(args, "-g", opts.include)
+	}
+	args = append(args, "-e", opts.pattern, "--", dir)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, rg, args...)
+	cmd.Dir = dir
+	cmd.Env = envutil.ShellEnv()
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, err
+	}
+	if err := cmd.Start(); err != nil {
+		return false, err
+	}
+
+	more := true
+	var current *grepFile
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var ev rgEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		switch ev.Type {
+		case "begin":
+			current = &grepFile{path: ev.Data.Path.Text}
+		case "match", "context":
+			if current == nil {
+				continue
+			}
+			if ev.Type == "match" {
+				current.matches++
+			}
+			text := strings.TrimSuffix(ev.Data.Lines.Text, "\n")
+			for i, l := range strings.Split(text, "\n") {
+				current.lines = append(current.lines, grepLine{
+					num:   ev.Data.LineNumber + i,
+					text:  strings.TrimSuffix(l, "\r"),
+					match: ev.Type == "match",
+				})
+			}
+		case "end":
+			f := current
+			current = nil
+			if f == nil || f.matches == 0 || f.path == "" || ws.Ignored(f.path, false) {
+				continue
+			}
+			if !c.add(*f) {
+				more = false
+			}
+		}
+		if !more {
+			break
+		}
+	}
+
+	if !more {
+		cancel()
+		cmd.Wait()
+		return false, nil
+	}
+	if err := cmd.Wait(); err != nil {
+		// Exit code 1 means no matches; anything else with no output is a real failure
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return true, nil
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if len(c.lines) == 0 && stderr.Len() > 0 {
+			return false, fmt.Errorf("rg: %s", strings.TrimSpace(stderr.String()))
+		}
+	}
+	return true, nil
+}
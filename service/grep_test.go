@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGrepWithGo(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("one\nTwo\nthree\nfour\nfive\ntwo\n"), 0o644)
+	os.WriteFile(filepath.Join(root, "b.go"), []byte("func main() {\n\treturn\n}\n"), 0o644)
+	os.WriteFile(filepath.Join(root, "bin.dat"), []byte("two\x00two"), 0o644)
+
+	ws := NewWorkspace(root, nil, nil)
+	grep := func(args map[string]interface{}) string {
+		t.Helper()
+		opts, err := parseGrepOptions(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		re, err := opts.compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &grepCollector{opts: opts, display: func(p string) string { return ws.RelPath(p) }}
+		if _, err := grepWithGo(context.Background(), ws, ws.Roots[0], re, opts, c); err != nil {
+			t.Fatal(err)
+		}
+		return c.result()
+	}
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"case sensitive", map[string]interface{}{"pattern": "two"}, "a.txt:6:two"},
+		{"case insensitive", map[string]interface{}{"pattern": "two", "case_sensitive": false}, "a.txt:2:Two\na.txt:6:two"},
+		{"context", map[string]interface{}{"pattern": "three|five", "context": float64(1)},
+			"a.txt-2-Two\na.txt:3:three\na.txt-4-four\na.txt:5:five\na.txt-6-two"},
+		{"separator", map[string]interface{}{"pattern": "^one|^two", "after": float64(1)},
+			"a.txt:1:one\na.txt-2-Two\n--\na.txt:6:two"},
+		{"files", map[string]interface{}{"pattern": "t", "output_mode": "files_with_matches"}, "a.txt\nb.go"},
+		{"count", map[string]interface{}{"pattern": "t", "output_mode": "count"}, "a.txt:2\nb.go:1"},
+		{"multiline", map[string]interface{}{"pattern": `\{\n\treturn`, "multiline": true}, "b.go:1:func main() {\nb.go:2:\treturn"},
+		{"include", map[string]interface{}{"pattern": "t", "include": "*.go"}, "b.go:2:\treturn"},
+	}
+	for _, tt := range tests {
+		if got := grep(tt.args); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	page := grep(map[string]interface{}{"pattern": "o", "limit": float64(2)})
+	if !strings.HasPrefix(page, "a.txt:1:one\na.txt:2:Two\n") || !strings.Contains(page, "offset=2") {
+		t.Errorf("unexpected first page:\n%s", page)
+	}
+	page = grep(map[string]interface{}{"pattern": "o", "limit": float64(2), "offset": float64(2)})
+	if !strings.HasPrefix(page, "a.txt:4:four\na.txt:6:two") {
+		t.Errorf("unexpected second page:\n%s", page)
+	}
+}
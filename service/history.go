@@ -0,0 +1,189 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/api"
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+)
+
+const (
+	defaultAgingAfterTurns = 3
+	defaultAgingMinSize    = 4096
+)
+
+// ageToolResults returns history for a model request with the large function
+// responses of earlier turns, and the files (inline data) sent after them,
+// replaced by short placeholders. A response ages once AfterTurns user turns
+// have followed the turn it belongs to. history itself is not modified.
+func ageToolResults(history []api.Content, policy config.ToolResultAgingConfig) []api.Content {
+	if policy.Disabled {
+		return history
+	}
+	afterTurns := policy.AfterTurns
+	if afterTurns <= 0 {
+		afterTurns = defaultAgingAfterTurns
+	}
+	minSize := policy.MinSize
+	if minSize <= 0 {
+		minSize = defaultAgingMinSize
+	}
+
+	// turnsAfter[i] is the number of user turns that start after history[i]
+	turnsAfter := make([]int, len(history))
+	turns := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		turnsAfter[i] = turns
+		if isUserTurn(history[i]) {
+			turns++
+		}
+	}
+
+	shaped := history
+	copied := false
+	var calls []*api.FunctionCall // calls of the last model content not yet answered
+	for i, content := range history {
+		if content.Role == "model" {
+			calls = calls[:0]
+			for _, p := range content.Parts {
+				if p.FunctionCall != nil {
+					calls = append(calls, p.FunctionCall)
+				}
+			}
+			continue
+		}
+
+		var parts []api.Part
+		replace := func(j int, part api.Part) {
+			if parts == nil {
+				parts = append([]api.Part(nil), content.Parts...)
+			}
+			parts[j] = part
+		}
+		var resp *api.FunctionResp // the response inline data belongs to, if it ages
+		var call *api.FunctionCall
+		for j, p := range content.Parts {
+			if p.InlineData != nil && resp != nil {
+				// File content read by a tool follows its function response
+				if size := base64.StdEncoding.DecodedLen(len(p.InlineData.Data)); size >= minSize {
+					replace(j, api.Part{Text: elidedPlaceholder(resp.Name, call, size, p.InlineData.MimeType)})
+				}
+				continue
+			}
+			resp = nil
+			if p.FunctionResp == nil {
+				continue
+			}
+			call = nil
+			for k, c := range calls {
+				if c.Name == p.FunctionResp.Name {
+					call = c
+					calls = append(calls[:k], calls[k+1:]...)
+					break
+				}
+			}
+			if turnsAfter[i] < afterTurns || !agesTool(policy.Tools, p.FunctionResp.Name) {
+				continue
+			}
+			resp = p.FunctionResp
+			data, _ := json.Marshal(p.FunctionResp.Response)
+			if len(data) < minSize {
+				continue
+			}
+			replace(j, api.Part{FunctionResp: &api.FunctionResp{
+				Name:     p.FunctionResp.Name,
+				Response: map[string]interface{}{"result": elidedPlaceholder(p.FunctionResp.Name, call, len(data), "")},
+			}})
+		}
+		if parts == nil {
+			continue
+		}
+		if !copied {
+			shaped = append([]api.Content(nil), history...)
+			copied = true
+		}
+		shaped[i] = api.Content{Role: content.Role, Parts: parts}
+	}
+	return shaped
+}
+
+// isUserTurn reports whether content is a message from the user rather than
+// the results of tool calls
+func isUserTurn(content api.Content) bool {
+	if content.Role != "user" {
+		return false
+	}
+	for _, p := range content.Parts {
+		if p.FunctionResp != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func agesTool(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, name); (err == nil && ok) || pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// elidedPlaceholder describes a removed result, such as
+// "[elided: 42KB read_file of foo.go, re-read if needed]", or the removed file
+// content of a result when mimeType is set:
+// "[elided: 1.2MB image/png from read_file of shot.png, re-read if needed]"
+func elidedPlaceholder(name string, call *api.FunctionCall, size int, mimeType string) string {
+	var target string
+	if call != nil {
+		for _, key := range []string{"file_path", "path", "dir_path", "pattern", "command", "url", "query", "handle"} {
+			if v, ok := call.Args[key].(string); ok && v != "" {
+				target = v
+				break
+			}
+		}
+		if paths, ok := call.Args["include"].([]interface{}); ok && target == "" && len(paths) > 0 {
+			target = fmt.Sprint(paths[0])
+			if len(paths) > 1 {
+				target += fmt.Sprintf(" and %d more", len(paths)-1)
+			}
+		}
+	}
+	if len(target) > 80 {
+		target = target[:77] + "..."
+	}
+	target = strings.ReplaceAll(target, "\n", " ")
+
+	var sb strings.Builder
+	sb.WriteString("[elided: " + formatSize(size) + " ")
+	if mimeType != "" {
+		sb.WriteString(mimeType + " from ")
+	}
+	sb.WriteString(name)
+	if target != "" {
+		sb.WriteString(" of " + target)
+	}
+	switch name {
+	case "read_file", "read_many_files", "read_tool_output":
+		sb.WriteString(", re-read if needed]")
+	default:
+		sb.WriteString(", call again if needed]")
+	}
+	return sb.String()
+}
+
+// formatSize formats a byte count in KB, or in MB from 1MB up
+func formatSize(size int) string {
+	if size >= 1<<20 {
+		return fmt.Sprintf("%.1fMB", float64(size)/(1<<20))
+	}
+	return fmt.Sprintf("%dKB", (size+1023)/1024)
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/api"
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+)
+
+func TestAgeToolResults(t *testing.T) {
+	big := strings.Repeat("x", 43000)
+	userText := func(text string) api.Content {
+		return api.Content{Role: "user", Parts: []api.Part{{Text: text}}}
+	}
+	call := func(name string, args map[string]interface{}) api.Content {
+		return api.Content{Role: "model", Parts: []api.Part{{FunctionCall: &api.FunctionCall{Name: name, Args: args}}}}
+	}
+	resp := func(name, result string) api.Content {
+		return api.Content{Role: "user", Parts: []api.Part{{FunctionResp: &api.FunctionResp{Name: name, Response: map[string]interface{}{"result": result}}}}}
+	}
+	history := []api.Content{
+		userText("read foo.go"),
+		call("read_file", map[string]interface{}{"file_path": "foo.go"}),
+		resp("read_file", big),
+		call("grep_search", map[string]interface{}{"pattern": "TODO"}),
+		resp("grep_search", "small"),
+		userText("second"),
+		userText("third"),
+		call("run_shell_command", map[string]interface{}{"command": "go test ./..."}),
+		resp("run_shell_command", big),
+		userText("fourth"),
+	}
+	result := func(h []api.Content, i int) string {
+		return h[i].Parts[0].FunctionResp.Response["result"].(string)
+	}
+
+	aged := ageToolResults(history, config.ToolResultAgingConfig{})
+	if got := result(aged, 2); got != "[elided: 43KB read_file of foo.go, re-read if needed]" {
+		t.Errorf("old large result: %q", got)
+	}
+	if result(aged, 4) != "small" || result(aged, 8) != big {
+		t.Error("small or recent results were elided")
+	}
+	if result(history, 2) != big {
+		t.Error("original history was modified")
+	}
+
+	aged = ageToolResults(history, config.ToolResultAgingConfig{AfterTurns: 1, Tools: []string{"run_*"}})
+	if result(aged, 2) != big || result(aged, 8) != "[elided: 43KB run_shell_command of go test ./..., call again if needed]" {
+		t.Errorf("tool filter not applied: %q %q", result(aged, 2)[:20], result(aged, 8))
+	}
+
+	if aged := ageToolResults(history, config.ToolResultAgingConfig{Disabled: true}); result(aged, 2) != big {
+		t.Error("disabled policy elided a result")
+	}
+}
+
+func TestAgeToolResultsInlineData(t *testing.T) {
+	image := base64.StdEncoding.EncodeToString(make([]byte, 1258290))
+	history := []api.Content{
+		{Role: "user", Parts: []api.Part{{Text: "look at shot.png"}}},
+		{Role: "model", Parts: []api.Part{{FunctionCall: &api.FunctionCall{Name: "read_file", Args: map[string]interface{}{"file_path": "shot.png"}}}}},
+		{Role: "user", Parts: []api.Part{
+			{FunctionResp: &api.FunctionResp{Name: "read_file", Response: map[string]interface{}{"result": "Read image/png file: shot.png"}}},
+			{InlineData: &api.InlineData{MimeType: "image/png", Data: image}},
+		}},
+		{Role: "user", Parts: []api.Part{{Text: "second"}}},
+		{Role: "user", Parts: []api.Part{{Text: "third"}}},
+		{Role: "user", Parts: []api.Part{{Text: "fourth"}}},
+	}
+
+	aged := ageToolResults(history, config.ToolResultAgingConfig{})
+	parts := aged[2].Parts
+	if parts[0].FunctionResp == nil || parts[0].FunctionResp.Response["result"] != "Read image/png file: shot.png" {
+		t.Errorf("small function response changed: %+v", parts[0])
+	}
+	if parts[1].InlineData != nil || parts[1].Text != "[elided: 1.2MB image/png from read_file of shot.png, re-read if needed]" {
+		t.Errorf("old inline data not elided: %q", parts[1].Text)
+	}
+	if history[2].Parts[1].InlineData == nil {
+		t.Error("original history was modified")
+	}
+
+	if aged := ageToolResults(history[:4], config.ToolResultAgingConfig{}); aged[2].Parts[1].InlineData == nil {
+		t.Error("recent inline data was elided")
+	}
+}
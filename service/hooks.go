@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+)
+
+// Hook events
+const (
+	HookBeforeTool   = "BeforeTool"
+	HookAfterTool    = "AfterTool"
+	HookBeforeModel  = "BeforeModel"
+	HookSessionStart = "SessionStart"
+	HookSessionEnd   = "SessionEnd"
+	HookStop         = "Stop"
+)
+
+// defaultHookTimeout bounds a hook command without a configured timeout
+const defaultHookTimeout = 60 * time.Second
+
+// hookOutput is the JSON a hook may print on standard output
+type hookOutput struct {
+	Decision   string `json:"decision"` // "block"/"deny" or "allow"/"approve"
+	Reason     string `json:"reason"`
+	Continue   *bool  `json:"continue"` // false stops the turn
+	StopReason string `json:"stopReason"`
+
+	HookSpecificOutput struct {
+		AdditionalContext string                 `json:"additionalContext"`
+		ToolInput         map[string]interface{} `json:"tool_input"` // BeforeTool: replaces the arguments
+	} `json:"hookSpecificOutput"`
+}
+
+// hookOutcome is the combined result of the hooks run for one event
+type hookOutcome struct {
+	Blocked    bool
+	Reason     string
+	Approved   bool // a BeforeTool hook allowed the call explicitly
+	Stop       bool // a hook asked to end the turn
+	StopReason string
+	ToolInput  map[string]interface{}
+	Context    []string // additional context for the model
+}
+
+// runHooks runs the hooks configured for event whose matcher matches target,
+// one after another. Each hook receives input plus the common fields as JSON
+// on standard input. A BeforeTool hook that replaces the arguments passes
+// them on to the next hook. Hooks that fail with an exit code other than 2
+// are ignored.
+func runHooks(ctx context.Context, cfg *config.Config, ws *Workspace, event, target string, input map[string]interface{}) hookOutcome {
+	var outcome hookOutcome
+	if cfg == nil {
+		return outcome
+	}
+	for _, m := range cfg.Hooks[event] {
+		if !hookMatches(m.Matcher, target) {
+			continue
+		}
+		for _, h := range m.Hooks {
+			if h.Command == "" || (h.Type != "" && h.Type != "command") {
+				continue
+			}
+			payload := map[string]interface{}{
+				"hook_event_name": event,
+				"cwd":             ws.WorkDir,
+				"timestamp":       time.Now().Format(time.RFC3339),
+			}
+			for k, v := range input {
+				payload[k] = v
+			}
+			if outcome.ToolInput != nil {
+				payload["tool_input"] = outcome.ToolInput
+			}
+
+			out, blockReason, err := runHookCommand(ctx, h, ws.WorkDir, payload)
+			if blockReason != "" {
+				outcome.Blocked, outcome.Reason = true, blockReason
+				return outcome
+			}
+			if err != nil || out == nil {
+				continue
+			}
+
+			switch strings.ToLower(out.Decision) {
+			case "block", "deny":
+				outcome.Blocked = true
+				outcome.Reason = out.Reason
+				if outcome.Reason == "" {
+					outcome.Reason = "blocked by " + event + " hook `" + h.Command + "`"
+				}
+			case "allow", "approve":
+				outcome.Approved = true
+			}
+			if out.Continue != nil && !*out.Continue {
+				outcome.Stop, outcome.StopReason = true, out.StopReason
+			}
+			if out.HookSpecificOutput.ToolInput != nil {
+				outcome.ToolInput = out.HookSpecificOutput.ToolInput
+			}
+			if c := strings.TrimSpace(out.HookSpecificOutput.AdditionalContext); c != "" {
+				outcome.Context = append(outcome.Context, c)
+			}
+			if outcome.Blocked || outcome.Stop {
+				return outcome
+			}
+		}
+	}
+	return outcome
+}
+
+func hookMatches(matcher, target string) bool {
+	if matcher == "" || matcher == "*" {
+		return true
+	}
+	re, err := regexp.Compile("^(?:" + matcher + ")$")
+	if err != nil {
+		return matcher == target
+	}
+	return re.MatchString(target)
+}
+
+// runHookCommand runs one hook. It returns the parsed JSON output (nil if the
+// hook printed none) or, for exit code 2, the reason for blocking.
+func runHookCommand(ctx context.Context, h config.HookCommand, dir string, payload map[string]interface{}) (*hookOutput, string, error) {
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	timeout := defaultHookTimeout
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd, cleanup, err := newShellCmd(ctx, h.Command, dir, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cleanup()
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	cmd.WaitDelay = 2 * time.Second
+	cmd.Env = append(cmd.Env, "GEMINI_PROJECT_DIR="+dir)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 && ctx.Err() == nil {
+			reason := strings.TrimSpace(stderr.String())
+			if reason == "" {
+				reason = fmt.Sprintf("blocked by hook `%s`", h.Command)
+			}
+			return nil, reason, nil
+		}
+		return nil, "", err
+	}
+
+	text := bytes.TrimSpace(stdout.Bytes())
+	if len(text) == 0 || text[0] != '{' {
+		return nil, "", nil
+	}
+	var out hookOutput
+	if err := json.Unmarshal(text, &out); err != nil {
+		return nil, "", fmt.Errorf("hook `%s` printed invalid JSON: %w", h.Command, err)
+	}
+	return &out, "", nil
+}
+
+// startSession runs the SessionStart hooks the first time a session streams
+// a response and keeps their additional context for the system prompt
+func (c *ChatService) startSession(ctx context.Context) {
+	c.mu.Lock()
+	if c.sessionStarted {
+		c.mu.Unlock()
+		return
+	}
+	c.sessionStarted = true
+	source := "startup"
+	if len(c.history) > 1 {
+		source = "resume"
+	}
+	c.mu.Unlock()
+
+	outcome := runHooks(ctx, c.settings.GetConfig(), c.workspace(), HookSessionStart, source, map[string]interface{}{"source": source})
+	c.mu.Lock()
+	c.hookContext = outcome.Context
+	c.mu.Unlock()
+}
+
+// endSession runs the SessionEnd hooks if the session has started
+func (c *ChatService) endSession(reason string) {
+	c.mu.Lock()
+	started := c.sessionStarted
+	c.sessionStarted = false
+	c.hookContext = nil
+	c.mu.Unlock()
+	if !started {
+		return
+	}
+	runHooks(context.Background(), c.settings.GetConfig(), c.workspace(), HookSessionEnd, reason, map[string]interface{}{"reason": reason})
+}
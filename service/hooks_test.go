@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+)
+
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	ws := NewWorkspace(t.TempDir(), nil, nil)
+	cfg := &config.Config{Hooks: map[string][]config.HookMatcher{
+		HookBeforeTool: {
+			{Matcher: "run_shell_command", Hooks: []config.HookCommand{
+				{Command: `grep -q prod.yaml && { echo "touches prod config" >&2; exit 2; }; exit 0`},
+			}},
+			{Matcher: "write_file|replace", Hooks: []config.HookCommand{
+				{Command: `echo '{"hookSpecificOutput": {"tool_input": {"file_path": "b.go"}, "additionalContext": "rewritten"}}'`},
+				{Command: `grep -q '"file_path":"b.go"' && echo '{"decision": "allow"}'`},
+			}},
+		},
+		HookStop: {{Hooks: []config.HookCommand{{Command: "exit 1"}}}},
+	}}
+	ctx := context.Background()
+	shell := func(cmd string) map[string]interface{} {
+		return map[string]interface{}{"tool_name": "run_shell_command", "tool_input": map[string]interface{}{"command": cmd}}
+	}
+
+	if o := runHooks(ctx, cfg, ws, HookBeforeTool, "run_shell_command", shell("cat deploy/prod.yaml")); !o.Blocked || o.Reason != "touches prod config" {
+		t.Errorf("expected a block, got %+v", o)
+	}
+	if o := runHooks(ctx, cfg, ws, HookBeforeTool, "run_shell_command", shell("ls")); o.Blocked {
+		t.Errorf("unexpected block: %+v", o)
+	}
+
+	o := runHooks(ctx, cfg, ws, HookBeforeTool, "write_file", map[string]interface{}{"tool_input": map[string]interface{}{"file_path": "a.go"}})
+	if o.ToolInput["file_path"] != "b.go" || !o.Approved || len(o.Context) != 1 {
+		t.Errorf("unexpected outcome: %+v", o)
+	}
+	if o := runHooks(ctx, cfg, ws, HookBeforeTool, "read_file", nil); o.Approved || o.Blocked {
+		t.Errorf("hooks ran for a tool their matcher does not match: %+v", o)
+	}
+	if o := runHooks(ctx, cfg, ws, HookStop, "", nil); o.Blocked {
+		t.Errorf("a failing hook should not block: %+v", o)
+	}
+}
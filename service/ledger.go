@@ -0,0 +1,168 @@
+package service
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/shellsafety"
+)
+
+// maxSnapshotFiles bounds the number of files compared around a shell command
+const maxSnapshotFiles = 20000
+
+// LedgerEntry records the state of a file before the session first changed it
+type LedgerEntry struct {
+	Path     string   `json:"path"` // absolute
+	Original string   `json:"original,omitempty"`
+	Existed  bool     `json:"existed"`
+	Unknown  bool     `json:"unknown,omitempty"` // first changed by a shell command, original content not captured
+	Tools    []string `json:"tools"`             // tools that changed the file, in order
+}
+
+// SessionChange is the combined change of one file since the session started
+type SessionChange struct {
+	Diff  FileDiff `json:"diff"`
+	Tools []string `json:"tools"`
+	Note  string   `json:"note,omitempty"` // why Diff.Diff is empty, if it is
+}
+
+// recordChanges adds the files of a successful tool call to the ledger.
+// The original content is only taken the first time a file is seen.
+func (c *ChatService) recordChanges(tool string, changes []fileChange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range changes {
+		if ch.oldPath != "" {
+			c.recordLocked(tool, LedgerEntry{Path: ch.oldPath, Original: ch.before, Existed: true})
+			c.recordLocked(tool, LedgerEntry{Path: ch.path})
+			continue
+		}
+		c.recordLocked(tool, LedgerEntry{Path: ch.path, Original: ch.before, Existed: ch.existed})
+	}
+}
+
+func (c *ChatService) recordLocked(tool string, entry LedgerEntry) {
+	for i := range c.changes {
+		e := &c.changes[i]
+		if e.Path == entry.Path {
+			if len(e.Tools) == 0 || e.Tools[len(e.Tools)-1] != tool {
+				e.Tools = append(e.Tools, tool)
+			}
+			return
+		}
+	}
+	entry.Tools = []string{tool}
+	c.changes = append(c.changes, entry)
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// mayChangeFiles reports whether a tool call may change files the builtin
+// file tools don't record themselves, so the workspace must be snapshotted
+// around it: project tools, and shell commands that are not read-only.
+func mayChangeFiles(name string, args map[string]interface{}) bool {
+	if name != "run_shell_command" {
+		return !IsBuiltinTool(name)
+	}
+	command, _ := args["command"].(string)
+	return shellsafety.Classify(command).Level != shellsafety.ReadOnly
+}
+
+// snapshotFiles records the modification time and size of every file in the
+// workspace roots, skipping ignored paths. It is used to detect files changed
+// by shell commands.
+func snapshotFiles(ws *Workspace) map[string]fileStamp {
+	snap := make(map[string]fileStamp)
+	for _, root := range ws.Roots {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if len(snap) >= maxSnapshotFiles {
+				return filepath.SkipAll
+			}
+			if path != root && ws.Ignored(path, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil {
+					snap[path] = fileStamp{info.ModTime(), info.Size()}
+				}
+			}
+			return nil
+		})
+	}
+	return snap
+}
+
+// recordShellChanges compares snapshots taken around a shell command and
+// adds created, modified and deleted files to the ledger. The original
+// content of modified files is unknown unless an earlier tool recorded it.
+func (c *ChatService) recordShellChanges(tool string, before, after map[string]fileStamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, st := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			c.recordLocked(tool, LedgerEntry{Path: path})
+		case old != st:
+			c.recordLocked(tool, LedgerEntry{Path: path, Existed: true, Unknown: true})
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			c.recordLocked(tool, LedgerEntry{Path: path, Existed: true, Unknown: true})
+		}
+	}
+}
+
+// GetSessionChanges returns every file changed in this session with a diff
+// from its content at the time of the first change to its current content.
+// Files whose content is back to the original are left out.
+func (c *ChatService) GetSessionChanges() []SessionChange {
+	c.mu.Lock()
+	entries := append([]LedgerEntry{}, c.changes...)
+	c.mu.Unlock()
+
+	ws := c.workspace()
+	var result []SessionChange
+	for _, e := range entries {
+		data, err := os.ReadFile(e.Path)
+		exists := err == nil
+		if !exists && !e.Existed {
+			continue // created and removed again
+		}
+		current := string(data)
+		if exists && e.Existed && !e.Unknown && current == e.Original {
+			continue
+		}
+
+		change := fileChange{path: e.Path, before: e.Original, after: current, existed: e.Existed, delete: !exists}
+		sc := SessionChange{Tools: e.Tools}
+		switch {
+		case e.Unknown:
+			sc.Diff = FileDiff{Path: ws.RelPath(e.Path), Status: "modified"}
+			if !exists {
+				sc.Diff.Status = "deleted"
+			}
+			sc.Note = "changed by a shell command; the original content was not recorded"
+		case isBinaryContent(data) || isBinaryContent([]byte(e.Original)):
+			sc.Diff = change.fileDiff(ws)
+			sc.Diff.Diff, sc.Diff.Added, sc.Diff.Removed = "", 0, 0
+			sc.Note = "binary file"
+		default:
+			sc.Diff = change.fileDiff(ws)
+		}
+		result = append(result, sc)
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionChanges(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("same\n"), 0o644)
+	c := NewChatService(&SettingsService{}, nil)
+	c.workDir = dir
+	ws := c.workspace()
+
+	apply := func(name string, args map[string]interface{}) {
+		t.Helper()
+		changes := planFileChanges(ws, name, args)
+		if _, err := ExecuteBuiltinTool(context.Background(), ws, name, args, nil); err != nil {
+			t.Fatal(err)
+		}
+		c.recordChanges(name, changes)
+	}
+	apply("replace", map[string]interface{}{"file_path": "a.txt", "old_string": "one", "new_string": "two"})
+	apply("replace", map[string]interface{}{"file_path": "a.txt", "old_string": "two", "new_string": "three"})
+	apply("write_file", map[string]interface{}{"file_path": "c.txt", "content": "new\n"})
+	apply("write_file", map[string]interface{}{"file_path": "b.txt", "content": "same\n"})
+
+	before := snapshotFiles(ws)
+	os.WriteFile(filepath.Join(dir, "d.txt"), []byte("shell\n"), 0o644)
+	c.recordShellChanges("run_shell_command", before, snapshotFiles(ws))
+
+	got := c.GetSessionChanges()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", got)
+	}
+	if got[0].Diff.Path != "a.txt" || got[0].Diff.Diff != "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+three\n" || len(got[0].Tools) != 1 {
+		t.Errorf("unexpected combined diff: %+v", got[0])
+	}
+	if got[1].Diff.Path != "c.txt" || got[1].Diff.Status != "added" {
+		t.Errorf("unexpected new file: %+v", got[1])
+	}
+	if got[2].Diff.Path != "d.txt" || got[2].Diff.Status != "added" || got[2].Tools[0] != "run_shell_command" {
+		t.Errorf("unexpected shell change: %+v", got[2])
+	}
+}
+
+func TestMayChangeFiles(t *testing.T) {
+	shell := func(command string) map[string]interface{} {
+		return map[string]interface{}{"command": command}
+	}
+	if mayChangeFiles("run_shell_command", shell("git status && ls -la")) {
+		t.Error("read-only shell command would be snapshotted")
+	}
+	if !mayChangeFiles("run_shell_command", shell("go generate ./...")) || !mayChangeFiles("run_shell_command", shell("echo x > out.txt")) {
+		t.Error("mutating shell command would not be snapshotted")
+	}
+	if mayChangeFiles("write_file", nil) || !mayChangeFiles("project_tool", nil) {
+		t.Error("builtin and project tools not told apart")
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+const (
+	// defaultMaxToolRoundTrips bounds the model requests answered with tool
+	// results in one user turn
+	defaultMaxToolRoundTrips = 100
+
+	// toolCallLoopThreshold is how many identical consecutive tool calls count as a loop
+	toolCallLoopThreshold = 5
+
+	// A content loop is contentChunkSize characters repeated
+	// contentLoopThreshold times, on average no further apart than
+	// contentMaxDistance
+	contentChunkSize     = 50
+	contentLoopThreshold = 10
+	contentMaxDistance   = contentChunkSize * 3 / 2
+)
+
+// loopDetector spots a model that is stuck: the same tool call made again
+// and again, or the same text streamed over and over. It lives for one user
+// turn.
+type loopDetector struct {
+	disabled bool
+
+	lastCall    string
+	callRepeats int
+
+	text    []byte
+	scanned int              // positions of text already hashed
+	chunks  map[uint64][]int // chunk hash -> recent end positions
+	inCode  bool             // inside a ``` fence, where repetition is normal
+}
+
+func newLoopDetector(enabled bool) *loopDetector {
+	return &loopDetector{disabled: !enabled, chunks: make(map[uint64][]int)}
+}
+
+// toolCall records a tool call and returns why it is a loop, or "". Calls
+// count as consecutive when no different call came between them, even if the
+// model wrote text in between.
+func (d *loopDetector) toolCall(name string, args map[string]interface{}) string {
+	if d.disabled {
+		return ""
+	}
+	argsJSON, _ := json.Marshal(args) // map keys are sorted
+	key := name + "\x00" + string(argsJSON)
+	if key == d.lastCall {
+		d.callRepeats++
+	} else {
+		d.lastCall, d.callRepeats = key, 1
+	}
+	// Text between tool calls is a new response
+	d.resetContent()
+	if d.callRepeats >= toolCallLoopThreshold {
+		return fmt.Sprintf("the model called %s with the same arguments %d times in a row", name, d.callRepeats)
+	}
+	return ""
+}
+
+// content records streamed text and returns why it is a loop, or ""
+func (d *loopDetector) content(chunk string) string {
+	if d.disabled {
+		return ""
+	}
+	d.text = append(d.text, chunk...)
+
+	for ; d.scanned < len(d.text); d.scanned++ {
+		end := d.scanned + 1
+		// Track code fences at line starts; repeated lines are normal in code
+		if end >= 3 && string(d.text[end-3:end]) == "```" && (end == 3 || d.text[end-4] == '\n') {
+			d.inCode = !d.inCode
+			d.chunks = make(map[uint64][]int)
+		}
+		if d.inCode || end < contentChunkSize {
+			continue
+		}
+		window := d.text[end-contentChunkSize : end]
+		if !varied(window) {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write(window)
+		sum := h.Sum64()
+
+		positions := append(d.chunks[sum], end)
+		if len(positions) > contentLoopThreshold {
+			positions = positions[1:]
+		}
+		d.chunks[sum] = positions
+		if len(positions) == contentLoopThreshold {
+			avg := (positions[len(positions)-1] - positions[0]) / (len(positions) - 1)
+			if avg <= contentMaxDistance {
+				return fmt.Sprintf("the model repeated the same text %d times: %q", contentLoopThreshold, strings.TrimSpace(string(window)))
+			}
+		}
+	}
+	return ""
+}
+
+func (d *loopDetector) resetContent() {
+	d.text, d.scanned, d.inCode = nil, 0, false
+	d.chunks = make(map[uint64][]int)
+}
+
+// varied reports whether a chunk has enough distinct bytes to be prose rather
+// than a rule line such as "-----" or "| --- | --- |"
+func varied(b []byte) bool {
+	var seen [256]bool
+	n := 0
+	for _, c := range b {
+		if !seen[c] {
+			seen[c] = true
+			n++
+		}
+	}
+	return n >= 8
+}
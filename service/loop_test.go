@@ -0,0 +1,57 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoopDetectorToolCalls(t *testing.T) {
+	d := newLoopDetector(true)
+	args := map[string]interface{}{"file_path": "a.go"}
+	for i := 1; i < toolCallLoopThreshold; i++ {
+		if reason := d.toolCall("read_file", args); reason != "" {
+			t.Fatalf("call %d reported a loop: %s", i, reason)
+		}
+	}
+	if reason := d.toolCall("read_file", map[string]interface{}{"file_path": "b.go"}); reason != "" {
+		t.Fatalf("different arguments reported a loop: %s", reason)
+	}
+	for i := 1; i <= toolCallLoopThreshold; i++ {
+		if reason := d.toolCall("read_file", args); (reason != "") != (i == toolCallLoopThreshold) {
+			t.Fatalf("call %d: unexpected result %q", i, reason)
+		}
+	}
+	// Text between identical calls does not reset the count
+	d = newLoopDetector(true)
+	var reason string
+	for i := 1; i <= toolCallLoopThreshold; i++ {
+		d.content("Let me check the file once more.")
+		reason = d.toolCall("read_file", args)
+	}
+	if reason == "" {
+		t.Error("identical calls with text between them were not detected")
+	}
+
+	if reason := newLoopDetector(false).toolCall("read_file", args); reason != "" {
+		t.Error("disabled detector reported a loop")
+	}
+}
+
+func TestLoopDetectorContent(t *testing.T) {
+	d := newLoopDetector(true)
+	prose := "The quick brown fox jumps over the lazy dog while the cat watches. "
+	if reason := d.content(strings.Repeat("Line of a table | a | b |\n", 3) + "```\n" + strings.Repeat("x := compute(value) // same line\n", 30) + "```\n" + prose); reason != "" {
+		t.Fatalf("normal text reported a loop: %s", reason)
+	}
+	if reason := d.content(strings.Repeat("-", 500)); reason != "" {
+		t.Fatalf("a rule line reported a loop: %s", reason)
+	}
+
+	var reason string
+	for i := 0; i < 20 && reason == ""; i++ {
+		reason = d.content("I will now read the file again to check. ")
+	}
+	if reason == "" {
+		t.Error("repeated text was not detected")
+	}
+}
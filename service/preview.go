@@ -0,0 +1,100 @@
+package service
+
+import (
+	"os"
+	"strings"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/diff"
+)
+
+// maxDiffSize caps the diff text sent to the UI for a single file
+const maxDiffSize = 200 * 1024
+
+// FileDiff is a unified diff of a file changed (or about to be changed) by a tool
+type FileDiff struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"oldPath,omitempty"` // set for renames
+	Status    string `json:"status"`            // "added" | "modified" | "deleted" | "renamed"
+	Diff      string `json:"diff"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// PreviewFileChanges computes the diffs a file-mutating builtin tool would
+// produce, without writing anything. It returns nil for other tools and for
+// calls that would fail.
+func PreviewFileChanges(ws *Workspace, name string, args map[string]interface{}) []FileDiff {
+	return fileDiffs(ws, planFileChanges(ws, name, args))
+}
+
+// planFileChanges computes the changes of a file-mutating builtin tool call
+func planFileChanges(ws *Workspace, name string, args map[string]interface{}) []fileChange {
+	switch name {
+	case "write_file":
+		if change, err := planWriteFile(ws, args); err == nil {
+			return []fileChange{change}
+		}
+	case "replace":
+		if change, _, _, err := planReplace(ws, args); err == nil {
+			return []fileChange{change}
+		}
+	case "multi_edit":
+		if change, _, err := planMultiEdit(ws, args); err == nil {
+			return []fileChange{change}
+		}
+	case "apply_patch":
+		if changes, _, err := planApplyPatch(ws, args); err == nil {
+			return changes
+		}
+	}
+	return nil
+}
+
+func fileDiffs(ws *Workspace, changes []fileChange) []FileDiff {
+	if len(changes) == 0 {
+		return nil
+	}
+	diffs := make([]FileDiff, 0, len(changes))
+	for _, change := range changes {
+		diffs = append(diffs, change.fileDiff(ws))
+	}
+	return diffs
+}
+
+func planWriteFile(ws *Workspace, args map[string]interface{}) (fileChange, error) {
+	filePath, _ := args["file_path"].(string)
+	content, _ := args["content"].(string)
+	filePath, err := ws.Resolve(filePath)
+	if err != nil {
+		return fileChange{}, err
+	}
+	change := fileChange{path: filePath, after: content}
+	if data, err := os.ReadFile(filePath); err == nil {
+		change.before, change.existed = string(data), true
+	}
+	return change, nil
+}
+
+func (c fileChange) fileDiff(ws *Workspace) FileDiff {
+	fd := FileDiff{Path: ws.RelPath(c.path), Status: "modified"}
+	oldName, newName := fd.Path, fd.Path
+	after := c.after
+	switch {
+	case c.delete:
+		fd.Status, newName, after = "deleted", "", ""
+	case !c.existed:
+		fd.Status, oldName = "added", ""
+	case c.oldPath != "":
+		fd.Status = "renamed"
+		fd.OldPath = ws.RelPath(c.oldPath)
+		oldName = fd.OldPath
+	}
+
+	fd.Diff, fd.Added, fd.Removed = diff.Unified(oldName, newName, c.before, after)
+	if len(fd.Diff) > maxDiffSize {
+		cut := strings.LastIndexByte(fd.Diff[:maxDiffSize], '\n') + 1
+		fd.Diff, fd.Truncated = fd.Diff[:cut], true
+	}
+	return fd
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreviewFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0o644)
+	ws := NewWorkspace(dir, nil, nil)
+
+	diffs := PreviewFileChanges(ws, "replace", map[string]interface{}{
+		"file_path": "a.txt", "old_string": "two", "new_string": "2\n3",
+	})
+	if len(diffs) != 1 || diffs[0].Path != "a.txt" || diffs[0].Status != "modified" || diffs[0].Added != 2 || diffs[0].Removed != 1 {
+		t.Fatalf("unexpected replace preview: %+v", diffs)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "one\ntwo\n" {
+		t.Errorf("preview modified the file: %q", data)
+	}
+
+	diffs = PreviewFileChanges(ws, "write_file", map[string]interface{}{"file_path": "b.txt", "content": "new\n"})
+	if len(diffs) != 1 || diffs[0].Status != "added" || diffs[0].Diff != "--- /dev/null\n+++ b/b.txt\n@@ -0,0 +1 @@\n+new\n" {
+		t.Errorf("unexpected write_file preview: %+v", diffs)
+	}
+
+	if diffs := PreviewFileChanges(ws, "replace", map[string]interface{}{"file_path": "a.txt", "old_string": "missing"}); diffs != nil {
+		t.Errorf("expected no preview for a failing call, got %+v", diffs)
+	}
+	if diffs := PreviewFileChanges(ws, "read_file", map[string]interface{}{"file_path": "a.txt"}); diffs != nil {
+		t.Errorf("expected no preview for read_file, got %+v", diffs)
+	}
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/sandbox"
+)
+
+const (
+	// processBufferSize is the amount of recent output kept per background process
+	processBufferSize = 256 * 1024
+
+	// startupOutputWait is how long Start waits to report early output or a quick exit
+	startupOutputWait = 500 * time.Millisecond
+)
+
+// ProcessManager runs shell commands in the background for the agent and
+// keeps the tail of their output
+type ProcessManager struct {
+	mu     sync.Mutex
+	procs  map[int]*bgProcess
+	nextID int
+}
+
+type bgProcess struct {
+	id      int
+	command string
+	dir     string
+	pid     int
+	started time.Time
+	out     *ringBuffer
+	readPos int64 // output offset already returned by read_process_output
+	kill    func() error
+
+	done     chan struct{}
+	exitCode int
+	exitErr  error
+	killed   bool
+}
+
+// NewProcessManager creates an empty process manager
+func NewProcessManager() *ProcessManager {
+	return &ProcessManager{procs: make(map[int]*bgProcess)}
+}
+
+type processManagerKey struct{}
+
+// withProcessManager returns a context whose tool calls can start and manage
+// background processes
+func withProcessManager(ctx context.Context, pm *ProcessManager) context.Context {
+	return context.WithValue(ctx, processManagerKey{}, pm)
+}
+
+func processManagerFrom(ctx context.Context) *ProcessManager {
+	pm, _ := ctx.Value(processManagerKey{}).(*ProcessManager)
+	return pm
+}
+
+// Start runs command in the background and returns its process ID along with
+// any output produced right after starting
+func (m *ProcessManager) Start(command, dir string, box *sandbox.Options) (string, error) {
+	cmd, cleanup, err := newShellCmd(context.Background(), command, dir, box)
+	if err != nil {
+		return "", err
+	}
+	out := newRingBuffer(processBufferSize)
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	if err := cmd.Start(); err != nil {
+		cleanup()
+		return "", fmt.Errorf("failed to start command: %w", err)
+	}
+
+	m.mu.Lock()
+	m.nextID++
+	p := &bgProcess{
+		id:      m.nextID,
+		command: command,
+		dir:     dir,
+		pid:     cmd.Process.Pid,
+		started: time.Now(),
+		out:     out,
+		kill: func() error {
+			cleanup()
+			return killProcessGroup(cmd)
+		},
+		done: make(chan struct{}),
+	}
+	m.procs[p.id] = p
+	m.mu.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+		cleanup()
+		m.mu.Lock()
+		p.exitErr = err
+		p.exitCode = cmd.ProcessState.ExitCode()
+		m.mu.Unlock()
+		close(p.done)
+	}()
+
+	select {
+	case <-p.done:
+	case <-time.After(startupOutputWait):
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Started background process %d (pid %d): %s\n", p.id, p.pid, command)
+	sb.WriteString(m.status(p) + "\n")
+	if output := m.readNew(p); output != "" {
+		sb.WriteString("Output so far:\n" + output)
+	}
+	sb.WriteString("\nUse read_process_output to check its output and kill_process to stop it.")
+	return sb.String(), nil
+}
+
+// ReadOutput returns the output of a process that was not returned before,
+// or all retained output if all is set
+func (m *ProcessManager) ReadOutput(id int, all bool) (string, error) {
+	p, err := m.get(id)
+	if err != nil {
+		return "", err
+	}
+
+	var output string
+	if all {
+		output, _ = p.out.ReadFrom(0)
+		m.mu.Lock()
+		p.readPos = p.out.Total()
+		m.mu.Unlock()
+	} else {
+		output = m.readNew(p)
+	}
+
+	result := fmt.Sprintf("Process %d: %s\n", p.id, m.status(p))
+	if output == "" {
+		return result + "(no new output)", nil
+	}
+	return result + output, nil
+}
+
+// List describes every background process started in this session
+func (m *ProcessManager) List() string {
+	m.mu.Lock()
+	procs := make([]*bgProcess, 0, len(m.procs))
+	for _, p := range m.procs {
+		procs = append(procs, p)
+	}
+	m.mu.Unlock()
+	if len(procs) == 0 {
+		return "No background processes"
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].id < procs[j].id })
+
+	var sb strings.Builder
+	for _, p := range procs {
+		fmt.Fprintf(&sb, "%d\tpid %d\t%s\t%s\n", p.id, p.pid, m.status(p), p.command)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// Kill stops a background process and every process it started
+func (m *ProcessManager) Kill(id int) (string, error) {
+	p, err := m.get(id)
+	if err != nil {
+		return "", err
+	}
+	select {
+	case <-p.done:
+		return fmt.Sprintf("Process %d already exited: %s", id, m.status(p)), nil
+	default:
+	}
+
+	m.mu.Lock()
+	p.killed = true
+	m.mu.Unlock()
+	p.kill()
+
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		return "", fmt.Errorf("process %d did not exit after being killed", id)
+	}
+	return fmt.Sprintf("Killed process %d: %s", id, p.command), nil
+}
+
+// KillAll stops every running background process (called on shutdown)
+func (m *ProcessManager) KillAll() {
+	m.mu.Lock()
+	var running []*bgProcess
+	for _, p := range m.procs {
+		select {
+		case <-p.done:
+		default:
+			p.killed = true
+			running = append(running, p)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, p := range running {
+		p.kill()
+	}
+	for _, p := range running {
+		select {
+		case <-p.done:
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (m *ProcessManager) get(id int) (*bgProcess, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.procs[id]
+	if !ok {
+		return nil, fmt.Errorf("no background process with ID %d (use list_processes)", id)
+	}
+	return p, nil
+}
+
+func (m *ProcessManager) readNew(p *bgProcess) string {
+	m.mu.Lock()
+	from := p.readPos
+	m.mu.Unlock()
+
+	output, dropped := p.out.ReadFrom(from)
+
+	m.mu.Lock()
+	p.readPos = from + dropped + int64(len(output))
+	m.mu.Unlock()
+
+	if dropped > 0 {
+		output = fmt.Sprintf("... (%d bytes of earlier output discarded)\n%s", dropped, output)
+	}
+	return output
+}
+
+func (m *ProcessManager) status(p *bgProcess) string {
+	select {
+	case <-p.done:
+	default:
+		return fmt.Sprintf("running for %s", time.Since(p.started).Round(time.Second))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p.killed {
+		return "killed"
+	}
+	if p.exitCode < 0 && p.exitErr != nil {
+		return "exited: " + p.exitErr.Error()
+	}
+	return fmt.Sprintf("exited with code %d", p.exitCode)
+}
+
+// ringBuffer keeps the last size bytes written to it
+type ringBuffer struct {
+	mu    sync.Mutex
+	data  []byte
+	size  int
+	total int64 // bytes written since creation
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{size: size}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.total += int64(len(p))
+	r.data = append(r.data, p...)
+	if over := len(r.data) - r.size; over > 0 {
+		r.data = append(r.data[:0], r.data[over:]...)
+	}
+	return len(p), nil
+}
+
+// Total returns the number of bytes written so far
+func (r *ringBuffer) Total() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total
+}
+
+// ReadFrom returns the retained output written at or after offset, and how
+// many bytes after offset were already discarded
+func (r *ringBuffer) ReadFrom(offset int64) (string, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start := r.total - int64(len(r.data)) // offset of data[0]
+	var dropped int64
+	if offset < start {
+		dropped = start - offset
+		offset = start
+	}
+	return string(r.data[offset-start:]), dropped
+}
+
+func execProcessTool(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	pm := processManagerFrom(ctx)
+	if pm == nil {
+		return "", fmt.Errorf("background processes are not available here")
+	}
+	if name == "list_processes" {
+		return pm.List(), nil
+	}
+
+	id, ok := args["process_id"].(float64)
+	if !ok {
+		return "", fmt.Errorf("process_id is required")
+	}
+	if name == "kill_process" {
+		return pm.Kill(int(id))
+	}
+	all, _ := args["all"].(bool)
+	return pm.ReadOutput(int(id), all)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestBackgroundProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash")
+	}
+	pm := NewProcessManager()
+	defer pm.KillAll()
+	ctx := withProcessManager(context.Background(), pm)
+
+	result, err := execShellCommand(ctx, NewWorkspace(t.TempDir(), nil, nil), map[string]interface{}{
+		"command":       "echo ready; sleep 30",
+		"is_background": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "Started background process 1") || !strings.Contains(result, "ready") {
+		t.Errorf("unexpected start result: %q", result)
+	}
+
+	// Output returned at start is not repeated
+	out, err := execProcessTool(ctx, "read_process_output", map[string]interface{}{"process_id": float64(1)})
+	if err != nil || !strings.Contains(out, "running") || !strings.Contains(out, "(no new output)") {
+		t.Errorf("unexpected output: %q %v", out, err)
+	}
+	if list, _ := execProcessTool(ctx, "list_processes", nil); !strings.Contains(list, "sleep 30") {
+		t.Errorf("unexpected list: %q", list)
+	}
+	if out, err := execProcessTool(ctx, "kill_process", map[string]interface{}{"process_id": float64(1)}); err != nil || !strings.Contains(out, "Killed") {
+		t.Errorf("kill: %q %v", out, err)
+	}
+	if out, _ := execProcessTool(ctx, "read_process_output", map[string]interface{}{"process_id": float64(1), "all": true}); !strings.Contains(out, "killed") || !strings.Contains(out, "ready") {
+		t.Errorf("unexpected output after kill: %q", out)
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	r := newRingBuffer(4)
+	r.Write([]byte("abc"))
+	if s, dropped := r.ReadFrom(0); s != "abc" || dropped != 0 {
+		t.Errorf("got %q, %d dropped", s, dropped)
+	}
+	r.Write([]byte("def"))
+	if s, dropped := r.ReadFrom(1); s != "cdef" || dropped != 1 {
+		t.Errorf("got %q, %d dropped", s, dropped)
+	}
+	if s, _ := r.ReadFrom(5); s != "f" {
+		t.Errorf("got %q", s)
+	}
+}
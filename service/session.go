@@ -25,14 +25,17 @@ type SessionSummary struct {
 
 // SessionData is the full session stored on disk
 type SessionData struct {
-	ID        string        `json:"id"`
-	Title     string        `json:"title"`
-	Model     string        `json:"model"`
-	WorkDir   string        `json:"workDir,omitempty"`
-	Messages  []ChatMessage `json:"messages"`
-	History   []api.Content `json:"history"`
-	CreatedAt time.Time     `json:"createdAt"`
-	UpdatedAt time.Time     `json:"updatedAt"`
+	ID                 string              `json:"id"`
+	Title              string              `json:"title"`
+	Model              string              `json:"model"`
+	Thinking           *api.ThinkingConfig `json:"thinking,omitempty"`
+	WorkDir            string              `json:"workDir,omitempty"`
+	IncludeDirectories []string            `json:"includeDirectories,omitempty"`
+	Changes            []LedgerEntry       `json:"changes,omitempty"`
+	Messages           []ChatMessage       `json:"messages"`
+	History            []api.Content       `json:"history"`
+	CreatedAt          time.Time           `json:"createdAt"`
+	UpdatedAt          time.Time           `json:"updatedAt"`
 }
 
 // SessionService manages session persistence
@@ -157,7 +160,10 @@ func (s *SessionService) SaveCurrentSession(id string) error {
 	hist := make([]api.Content, len(s.chat.history))
 	copy(hist, s.chat.history)
 	model := s.chat.model
+	thinking := copyThinkingConfig(s.chat.thinking)
 	workDir := s.chat.workDir
+	includeDirs := append([]string{}, s.chat.includeDirs...)
+	changes := append([]LedgerEntry{}, s.chat.changes...)
 	s.chat.mu.Unlock()
 
 	if len(msgs) == 0 {
@@ -186,14 +192,17 @@ func (s *SessionService) SaveCurrentSession(id string) error {
 	}
 
 	sd := SessionData{
-		ID:        id,
-		Title:     title,
-		Model:     model,
-		WorkDir:   workDir,
-		Messages:  msgs,
-		History:   hist,
-		CreatedAt: createdAt,
-		UpdatedAt: now,
+		ID:                 id,
+		Title:              title,
+		Model:              model,
+		Thinking:           thinking,
+		WorkDir:            workDir,
+		IncludeDirectories: includeDirs,
+		Changes:            changes,
+		Messages:           msgs,
+		History:            hist,
+		CreatedAt:          createdAt,
+		UpdatedAt:          now,
 	}
 
 	data, err := json.MarshalIndent(sd, "", "  ")
@@ -214,12 +223,17 @@ func (s *SessionService) LoadSession(id string) error {
 		return fmt.Errorf("session %s not found", id)
 	}
 
+	s.chat.endSession("switch")
 	s.chat.mu.Lock()
 	s.chat.messages = sd.Messages
 	s.chat.history = sd.History
 	s.chat.model = sd.Model
+	s.chat.thinking = sd.Thinking
 	s.chat.workDir = sd.WorkDir
+	s.chat.includeDirs = sd.IncludeDirectories
+	s.chat.changes = sd.Changes
 	s.chat.mu.Unlock()
+	s.chat.outputs.Clear()
 
 	return nil
 }
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/api"
+)
+
+func TestSessionThinkingRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	chat := NewChatService(nil, nil)
+	sessions := NewSessionService(chat)
+
+	budget := 2048
+	chat.SetThinkingConfig(&api.ThinkingConfig{ThinkingBudget: &budget, IncludeThoughts: true})
+	chat.messages = []ChatMessage{{ID: "u", Role: "user", Content: "hello"}}
+	if err := sessions.SaveCurrentSession("session-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	chat.SetThinkingConfig(nil)
+	if err := sessions.LoadSession("session-1"); err != nil {
+		t.Fatal(err)
+	}
+	got := chat.GetThinkingConfig()
+	if got == nil || got.ThinkingBudget == nil || *got.ThinkingBudget != 2048 || !got.IncludeThoughts {
+		t.Errorf("thinking config not restored: %+v", got)
+	}
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/envutil"
+	"github.com/tomohiro-owada/gmn-gui/internal/sandbox"
+)
+
+const (
+	defaultShellTimeout = 120 * time.Second
+
+	// outputFlushInterval is how often buffered output is streamed to the UI
+	outputFlushInterval = 100 * time.Millisecond
+)
+
+type outputStreamKey struct{}
+
+// withOutputStream returns a context whose tool calls stream their output to fn
+// while they run (currently run_shell_command)
+func withOutputStream(ctx context.Context, fn func(chunk string)) context.Context {
+	return context.WithValue(ctx, outputStreamKey{}, fn)
+}
+
+func outputStreamFrom(ctx context.Context) func(string) {
+	fn, _ := ctx.Value(outputStreamKey{}).(func(string))
+	return fn
+}
+
+func execShellCommand(ctx context.Context, ws *Workspace, args map[string]interface{}) (string, error) {
+	command, _ := args["command"].(string)
+	if command == "" {
+		return "", fmt.Errorf("command is required")
+	}
+
+	// The sandbox profiles need an absolute directory inside the workspace
+	// (bwrap --chdir, docker -w)
+	dir := ws.WorkDir
+	if d, ok := args["dir_path"].(string); ok && d != "" {
+		resolved, err := ws.Resolve(d)
+		if err != nil {
+			return "", err
+		}
+		dir = resolved
+	}
+
+	if bg, _ := args["is_background"].(bool); bg {
+		pm := processManagerFrom(ctx)
+		if pm == nil {
+			return "", fmt.Errorf("background processes are not available here")
+		}
+		return pm.Start(command, dir, sandboxFrom(ctx))
+	}
+
+	timeout := defaultShellTimeout
+	switch v := args["timeout"].(type) {
+	case float64:
+		if v > 0 {
+			timeout = time.Duration(v) * time.Millisecond
+		}
+	case int:
+		if v > 0 {
+			timeout = time.Duration(v) * time.Millisecond
+		}
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd, cleanup, err := newShellCmd(timeoutCtx, command, dir, sandboxFrom(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	// Kill the whole process tree on timeout or cancellation, and stop waiting
+	// for output shortly after in case a detached child keeps the pipes open
+	cmd.Cancel = func() error {
+		cleanup()
+		return killProcessGroup(cmd)
+	}
+	cmd.WaitDelay = 2 * time.Second
+
+	out := newStreamWriter(outputStreamFrom(ctx))
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	err = cmd.Run()
+	out.Close()
+
+	result := out.String()
+
+	switch {
+	case errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil:
+		return fmt.Sprintf("%s\nCommand timed out after %s and was killed", result, timeout), nil
+	case ctx.Err() != nil:
+		return result, fmt.Errorf("command cancelled")
+	}
+
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Sprintf("%s\nExit code: %d", result, exitErr.ExitCode()), nil
+		}
+		return result, fmt.Errorf("command error: %w", err)
+	}
+
+	if result == "" {
+		result = "(empty output)"
+	}
+
+	return result, nil
+}
+
+type sandboxKey struct{}
+
+// withSandbox returns a context whose shell commands run in the given sandbox
+func withSandbox(ctx context.Context, sb *sandbox.Options) context.Context {
+	return context.WithValue(ctx, sandboxKey{}, sb)
+}
+
+func sandboxFrom(ctx context.Context) *sandbox.Options {
+	sb, _ := ctx.Value(sandboxKey{}).(*sandbox.Options)
+	return sb
+}
+
+// sandboxOptions returns the sandbox configured in settings for the
+// workspace, or nil if shell commands are not sandboxed
+func sandboxOptions(settings *SettingsService, ws *Workspace) *sandbox.Options {
+	if settings == nil {
+		return nil
+	}
+	cfg := settings.GetConfig()
+	if cfg == nil {
+		return nil
+	}
+	sb := cfg.SandboxSettings()
+	if !sb.Enabled {
+		return nil
+	}
+
+	writable := append([]string{}, ws.Roots...)
+	for _, p := range sb.ReadWritePaths {
+		writable = appendUnique(writable, ws.normalizeDir(p))
+	}
+	return &sandbox.Options{
+		Profile:       sb.Profile,
+		WritablePaths: writable,
+		Network:       sb.Network,
+		Image:         sb.Image,
+		Env:           sb.Env,
+	}
+}
+
+// newShellCmd builds the shell invocation for command, in its own process
+// group and, if sb is set, inside the sandbox. The returned cleanup releases
+// what the sandbox leaves behind and is safe to call more than once.
+func newShellCmd(ctx context.Context, command, dir string, sb *sandbox.Options) (*exec.Cmd, func(), error) {
+	var argv []string
+	if runtime.GOOS == "windows" {
+		// Windows: use cmd /c for simple commands, powershell for complex ones
+		argv = []string{"powershell", "-NoProfile", "-Command", command}
+	} else {
+		// Unix-like: use bash
+		argv = []string{"bash", "-c", command}
+	}
+	env := envutil.ShellEnv()
+	cleanup := func() {}
+
+	if sb != nil {
+		opts := *sb
+		opts.Dir = dir
+		wrapped, err := sandbox.Wrap(opts, argv, env)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sandbox: %w", err)
+		}
+		argv, env, cleanup = wrapped.Args, wrapped.Env, wrapped.Cleanup
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	cmd.Env = env
+	setProcessGroup(cmd)
+	return cmd, cleanup, nil
+}
+
+// streamWriter collects command output and, if fn is set, passes new output
+// to it in chunks at most every outputFlushInterval
+type streamWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	pending bytes.Buffer
+	fn      func(string)
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+func newStreamWriter(fn func(string)) *streamWriter {
+	w := &streamWriter{fn: fn, done: make(chan struct{})}
+	if fn != nil {
+		w.stopped.Add(1)
+		go w.flushLoop()
+	}
+	return w
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	if w.fn != nil {
+		w.pending.Write(p)
+	}
+	return len(p), nil
+}
+
+func (w *streamWriter) flushLoop() {
+	defer w.stopped.Done()
+	ticker := time.NewTicker(outputFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.done:
+			w.flush()
+			return
+		}
+	}
+}
+
+func (w *streamWriter) flush() {
+	w.mu.Lock()
+	chunk := w.pending.String()
+	w.pending.Reset()
+	w.mu.Unlock()
+	if chunk != "" {
+		w.fn(chunk)
+	}
+}
+
+// Close streams any remaining output
+func (w *streamWriter) Close() {
+	close(w.done)
+	w.stopped.Wait()
+}
+
+func (w *streamWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
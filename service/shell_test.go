@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShellCommandStreamsAndTimesOut(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash")
+	}
+
+	var mu sync.Mutex
+	var chunks []string
+	ctx := withOutputStream(context.Background(), func(s string) {
+		mu.Lock()
+		chunks = append(chunks, s)
+		mu.Unlock()
+	})
+
+	// The background sleep keeps the pipe open; killing only bash would hang until it exits
+	start := time.Now()
+	result, err := execShellCommand(ctx, NewWorkspace(t.TempDir(), nil, nil), map[string]interface{}{
+		"command": "echo started; sleep 30 & sleep 30",
+		"timeout": float64(300),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timeout not enforced, took %s", elapsed)
+	}
+	if !strings.Contains(result, "started") || !strings.Contains(result, "timed out") {
+		t.Errorf("unexpected result: %q", result)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(chunks, "") != "started\n" {
+		t.Errorf("unexpected streamed output: %q", chunks)
+	}
+}
+
+func TestShellCommandDirPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash")
+	}
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	ws := NewWorkspace(dir, nil, nil)
+
+	result, err := execShellCommand(context.Background(), ws, map[string]interface{}{"command": "pwd -P", "dir_path": "sub"})
+	if want := resolveSymlinks(filepath.Join(dir, "sub")); err != nil || !strings.Contains(result, want) {
+		t.Errorf("relative dir_path not resolved in the workspace: %q (%v), want %s", result, err, want)
+	}
+	if _, err := execShellCommand(context.Background(), ws, map[string]interface{}{"command": "pwd", "dir_path": ".."}); err == nil || !strings.Contains(err.Error(), "outside the workspace") {
+		t.Errorf("dir_path outside the workspace not rejected: %v", err)
+	}
+}
+
+func TestPlanModeDenial(t *testing.T) {
+	shell := func(cmd string) map[string]interface{} { return map[string]interface{}{"command": cmd} }
+	if reason := PlanModeDenial("run_shell_command", shell("git log --oneline | head")); reason != "" {
+		t.Errorf("read-only command denied: %s", reason)
+	}
+	if PlanModeDenial("run_shell_command", shell("go test ./...")) == "" {
+		t.Error("mutating command allowed")
+	}
+	if PlanModeDenial("run_shell_command", map[string]interface{}{"command": "ls", "is_background": true}) == "" {
+		t.Error("background command allowed")
+	}
+	if PlanModeDenial("write_file", nil) == "" || PlanModeDenial("read_file", nil) != "" {
+		t.Error("builtin plan mode tools not respected")
+	}
+	for _, name := range []string{"apply_patch", "multi_edit", "replace"} {
+		if PlanModeDenial(name, nil) == "" || IsPlanModeTool(name) {
+			t.Errorf("%s is allowed in plan mode", name)
+		}
+	}
+}
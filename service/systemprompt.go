@@ -8,6 +8,9 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+	"github.com/tomohiro-owada/gmn-gui/internal/ignore"
 )
 
 // coreSystemPrompt is the system instruction based on the official Gemini CLI.
@@ -65,8 +68,9 @@ When requested to perform tasks like fixing bugs, adding features, refactoring,
 # Final Reminder
 Your core function is efficient and safe assistance. Balance extreme conciseness with the crucial need for clarity. Always prioritize user control and project conventions. Never make assumptions about the contents of files; instead use tools to verify. Finally, you are an agent - please keep going until the user's query is completely resolved.`
 
-// BuildSystemPrompt builds the full system instruction including environment context.
-func BuildSystemPrompt(workDir string) string {
+// BuildSystemPrompt builds the full system instruction including environment context
+// for each workspace root and the context files of enabled extensions.
+func BuildSystemPrompt(workDir string, includeDirs []string, extensions []config.Extension) string {
 	var sb strings.Builder
 
 	sb.WriteString(coreSystemPrompt)
@@ -79,20 +83,27 @@ func BuildSystemPrompt(workDir string) string {
 
 	if workDir != "" {
 		sb.WriteString(fmt.Sprintf("Current working directory: %s\n", workDir))
-		sb.WriteString("\nFolder structure of the current working directory:\n\n")
-		sb.WriteString("```\n")
-		sb.WriteString(buildDirectoryTree(workDir, 200))
-		sb.WriteString("```\n")
-
-		// Check if git repo
-		if _, err := os.Stat(filepath.Join(workDir, ".git")); err == nil {
-			sb.WriteString("\nThis directory is managed by a git repository.\n")
+		if len(includeDirs) > 0 {
+			sb.WriteString("Additional workspace directories:\n")
+			for _, dir := range includeDirs {
+				sb.WriteString(fmt.Sprintf("- %s\n", dir))
+			}
 		}
+		writeWorkspaceContext(&sb, workDir, "the current working directory")
+	}
 
-		// Load GEMINI.md if present
-		geminiMD := filepath.Join(workDir, "GEMINI.md")
-		if data, err := os.ReadFile(geminiMD); err == nil {
-			sb.WriteString("\n# Project Instructions (GEMINI.md)\n\n")
+	for _, dir := range includeDirs {
+		writeWorkspaceContext(&sb, dir, dir)
+	}
+
+	// Append extension context files (e.g. an extension's GEMINI.md)
+	for _, ext := range extensions {
+		for _, path := range ext.ContextFiles {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("\n# Extension Instructions (%s: %s)\n\n", ext.Name, filepath.Base(path)))
 			sb.WriteString(string(data))
 			sb.WriteString("\n")
 		}
@@ -101,11 +112,33 @@ func BuildSystemPrompt(workDir string) string {
 	return sb.String()
 }
 
+// writeWorkspaceContext writes the folder structure, git status and GEMINI.md of one workspace root
+func writeWorkspaceContext(sb *strings.Builder, dir, label string) {
+	sb.WriteString(fmt.Sprintf("\nFolder structure of %s:\n\n", label))
+	sb.WriteString("```\n")
+	sb.WriteString(buildDirectoryTree(dir, 200))
+	sb.WriteString("```\n")
+
+	// Check if git repo
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		sb.WriteString(fmt.Sprintf("\n%s is managed by a git repository.\n", dir))
+	}
+
+	// Load GEMINI.md if present
+	geminiMD := filepath.Join(dir, "GEMINI.md")
+	if data, err := os.ReadFile(geminiMD); err == nil {
+		sb.WriteString(fmt.Sprintf("\n# Project Instructions (%s)\n\n", geminiMD))
+		sb.WriteString(string(data))
+		sb.WriteString("\n")
+	}
+}
+
 // buildDirectoryTree creates a tree representation of the directory structure.
 // maxItems limits the total number of entries to prevent excessive output.
 func buildDirectoryTree(root string, maxItems int) string {
 	var sb strings.Builder
 	count := 0
+	ignored := ignore.New(root)
 
 	sb.WriteString(root + "/\n")
 
@@ -130,11 +163,11 @@ func buildDirectoryTree(root string, maxItems int) string {
 
 		name := entry.Name()
 
-		// Skip hidden dirs (except .git indicator), node_modules, build artifacts
+		// Skip hidden dirs (except .git indicator) and anything .gitignore/.geminiignore excludes
 		if strings.HasPrefix(name, ".") && name != ".git" {
 			continue
 		}
-		if name == "node_modules" || name == "vendor" || name == "__pycache__" || name == "dist" || name == "build" {
+		if name != ".git" && ignored.Match(name, entry.IsDir()) {
 			continue
 		}
 
@@ -161,7 +194,7 @@ func buildDirectoryTree(root string, maxItems int) string {
 						break
 					}
 					subName := sub.Name()
-					if strings.HasPrefix(subName, ".") {
+					if strings.HasPrefix(subName, ".") || ignored.Match(name+"/"+subName, sub.IsDir()) {
 						continue
 					}
 					subIsLast := j == len(subEntries)-1
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxToolResultSize is the largest tool output sent to the model as is.
+	// Longer output is stored in a file and only its head and tail are sent.
+	maxToolResultSize = 40000
+
+	// spillHeadSize and spillTailSize are the bytes kept from the start and
+	// the end of a stored output; errors and test failures usually come last
+	spillHeadSize = 8000
+	spillTailSize = 24000
+
+	// defaultOutputPageLines is the read_tool_output page size without a limit
+	defaultOutputPageLines = 500
+)
+
+// ToolOutputStore keeps the full text of tool outputs that were too long to
+// send to the model, in a temporary directory that lives as long as the
+// session
+type ToolOutputStore struct {
+	mu      sync.Mutex
+	dir     string
+	nextID  int
+	outputs map[string]string // handle -> file
+}
+
+// NewToolOutputStore creates an empty store. Its directory is created when
+// the first output is stored.
+func NewToolOutputStore() *ToolOutputStore {
+	return &ToolOutputStore{outputs: make(map[string]string)}
+}
+
+type toolOutputStoreKey struct{}
+
+// withToolOutputStore returns a context whose tool calls can read stored
+// tool outputs
+func withToolOutputStore(ctx context.Context, s *ToolOutputStore) context.Context {
+	return context.WithValue(ctx, toolOutputStoreKey{}, s)
+}
+
+func toolOutputStoreFrom(ctx context.Context) *ToolOutputStore {
+	s, _ := ctx.Value(toolOutputStoreKey{}).(*ToolOutputStore)
+	return s
+}
+
+// Spill returns output unchanged if it is short enough. Otherwise it stores
+// the full output and returns its head and tail around a note naming the
+// handle to read the rest with, along with that handle.
+func (s *ToolOutputStore) Spill(output string) (string, string) {
+	if len(output) <= maxToolResultSize {
+		return output, ""
+	}
+	handle, err := s.store(output)
+	if err != nil {
+		// Without a file to page through, keep the end, where errors are
+		return fmt.Sprintf("... (%d bytes omitted; the full output could not be stored: %v)\n%s",
+			len(output)-maxToolResultSize, err, output[len(output)-maxToolResultSize:]), ""
+	}
+
+	head := output[:spillHeadSize]
+	if i := strings.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i+1]
+	}
+	tail := output[len(output)-spillTailSize:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	total := countLines(output)
+	headLines := strings.Count(head, "\n")
+	tailStart := total - countLines(tail) // 0-based line where tail starts
+
+	var sb strings.Builder
+	sb.WriteString(head)
+	if !strings.HasSuffix(head, "\n") {
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "... [lines %d-%d of %d (%d of %d bytes) omitted. The full output is stored as %q: call read_tool_output with handle %q and offset %d to read them.] ...\n",
+		headLines+1, tailStart, total, len(output)-len(head)-len(tail), len(output), handle, handle, headLines)
+	sb.WriteString(tail)
+	return sb.String(), handle
+}
+
+func (s *ToolOutputStore) store(output string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir == "" {
+		base := filepath.Join(os.TempDir(), "gmn-gui")
+		if err := os.MkdirAll(base, 0o700); err != nil {
+			return "", err
+		}
+		dir, err := os.MkdirTemp(base, "tool-output-")
+		if err != nil {
+			return "", err
+		}
+		s.dir = dir
+	}
+	s.nextID++
+	handle := fmt.Sprintf("output-%d", s.nextID)
+	path := filepath.Join(s.dir, handle+".txt")
+	if err := os.WriteFile(path, []byte(output), 0o600); err != nil {
+		return "", err
+	}
+	s.outputs[handle] = path
+	return handle, nil
+}
+
+// Get returns the full text of a stored output
+func (s *ToolOutputStore) Get(handle string) (string, error) {
+	s.mu.Lock()
+	path, ok := s.outputs[handle]
+	s.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("no stored tool output %q (outputs are kept only for the current session)", handle)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stored output %q: %w", handle, err)
+	}
+	return string(data), nil
+}
+
+// Read returns up to limit lines of a stored output starting at the 0-based
+// line offset, numbered like read_file. A page stops early rather than grow
+// past maxToolResultSize.
+func (s *ToolOutputStore) Read(handle string, offset, limit int) (string, error) {
+	text, err := s.Get(handle)
+	if err != nil {
+		return "", err
+	}
+	if limit <= 0 {
+		limit = defaultOutputPageLines
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(lines) {
+		return fmt.Sprintf("(offset beyond end of output; it has %d lines)", len(lines)), nil
+	}
+
+	var sb strings.Builder
+	end := offset
+	for ; end < len(lines) && end < offset+limit; end++ {
+		line := fmt.Sprintf("%d: %s\n", end+1, lines[end])
+		if sb.Len() > 0 && sb.Len()+len(line) > maxToolResultSize {
+			break
+		}
+		if len(line) > maxToolResultSize {
+			line = line[:maxToolResultSize] + "... (line cut)\n"
+		}
+		sb.WriteString(line)
+	}
+	if end < len(lines) {
+		fmt.Fprintf(&sb, "... (%d more lines; call again with offset=%d)", len(lines)-end, end)
+	} else {
+		sb.WriteString("(end of output)")
+	}
+	return sb.String(), nil
+}
+
+// Clear deletes every stored output
+func (s *ToolOutputStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+	s.dir = ""
+	s.outputs = make(map[string]string)
+}
+
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	n := strings.Count(s, "\n")
+	if !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
+
+func execReadToolOutput(ctx context.Context, args map[string]interface{}) (string, error) {
+	store := toolOutputStoreFrom(ctx)
+	if store == nil {
+		return "", fmt.Errorf("stored tool outputs are not available here")
+	}
+	handle, _ := args["handle"].(string)
+	if handle == "" {
+		return "", fmt.Errorf("handle is required")
+	}
+	offset, _ := args["offset"].(float64)
+	limit, _ := args["limit"].(float64)
+	return store.Read(handle, int(offset), int(limit))
+}
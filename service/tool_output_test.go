@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestToolOutputSpill(t *testing.T) {
+	store := NewToolOutputStore()
+	defer store.Clear()
+
+	if out, handle := store.Spill("short"); out != "short" || handle != "" {
+		t.Fatalf("short output changed: %q %q", out, handle)
+	}
+
+	var sb strings.Builder
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintf(&sb, "line %d of the build log\n", i)
+	}
+	sb.WriteString("FAIL: TestSomething")
+	full := sb.String()
+
+	out, handle := store.Spill(full)
+	if handle == "" || len(out) > maxToolResultSize {
+		t.Fatalf("output not shortened: %d bytes, handle %q", len(out), handle)
+	}
+	if !strings.HasPrefix(out, "line 1 of") || !strings.HasSuffix(out, "FAIL: TestSomething") || !strings.Contains(out, handle) {
+		t.Errorf("head, tail or handle missing:\n%s", out)
+	}
+	if got, err := store.Get(handle); err != nil || got != full {
+		t.Errorf("stored output differs: %v", err)
+	}
+
+	ctx := withToolOutputStore(context.Background(), store)
+	page, err := execReadToolOutput(ctx, map[string]interface{}{"handle": handle, "offset": float64(1000), "limit": float64(2)})
+	if err != nil || page != "1001: line 1001 of the build log\n1002: line 1002 of the build log\n... (3999 more lines; call again with offset=1002)" {
+		t.Errorf("unexpected page: %q %v", page, err)
+	}
+	if page, _ := store.Read(handle, 4999, 0); !strings.HasSuffix(page, "5001: FAIL: TestSomething\n(end of output)") {
+		t.Errorf("unexpected last page: %q", page)
+	}
+
+	store.Clear()
+	if _, err := store.Get(handle); err == nil {
+		t.Error("output still available after Clear")
+	}
+}
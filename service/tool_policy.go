@@ -0,0 +1,256 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/api"
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+	"github.com/tomohiro-owada/gmn-gui/internal/shellsafety"
+)
+
+// ToolPolicy applies the tools.allowed and tools.exclude rules of the
+// settings layers (global, project, extensions). A call is denied when any
+// layer excludes it, or when any layer with an allowed list does not list it,
+// so later layers such as a repository's settings can only narrow what the
+// user's own settings allow.
+//
+// A rule is a tool name, where "*" matches any characters ("github__*"), or
+// a shell command prefix such as "run_shell_command(git status)". Command
+// prefixes are matched word by word against every command of a pipeline or
+// list, so "git status && rm x" is not covered by "run_shell_command(git status)".
+// Commands are compared by name without directory and without git's global
+// options, so "run_shell_command(git push)" also excludes `/usr/bin/git -C . push`.
+// Command lines that redirect output to a file are only allowed by prefix
+// rules ending in ">", e.g. "run_shell_command(git log >)".
+type ToolPolicy struct {
+	layers []config.ToolPolicyLayer
+}
+
+// NewToolPolicy returns the policy of cfg (allowing everything if cfg is nil)
+func NewToolPolicy(cfg *config.Config) *ToolPolicy {
+	if cfg == nil {
+		return &ToolPolicy{}
+	}
+	return &ToolPolicy{layers: cfg.ToolPolicy}
+}
+
+type toolRule struct {
+	text      string
+	name      string
+	prefix    string // command prefix of a scoped rule
+	scoped    bool
+	redirects bool // the prefix ends in ">": output may be redirected to files
+}
+
+func parseToolRule(rule string) toolRule {
+	r := toolRule{text: rule, name: strings.TrimSpace(rule)}
+	if open := strings.IndexByte(r.name, '('); open > 0 && strings.HasSuffix(r.name, ")") {
+		r.prefix = strings.TrimSpace(r.name[open+1 : len(r.name)-1])
+		r.name = strings.TrimSpace(r.name[:open])
+		r.scoped = true
+		if strings.HasSuffix(r.prefix, ">") {
+			r.prefix = strings.TrimSpace(strings.TrimSuffix(r.prefix, ">"))
+			r.redirects = true
+		}
+	}
+	return r
+}
+
+func (r toolRule) matchesName(name string) bool {
+	if ok, err := path.Match(r.name, name); err == nil {
+		return ok
+	}
+	return r.name == name
+}
+
+func describeLayer(l config.ToolPolicyLayer) string {
+	if l.Path == "" {
+		return l.Source
+	}
+	return fmt.Sprintf("%s (%s)", l.Source, l.Path)
+}
+
+// allowLayers returns the layers with an allowed list; a call must be
+// allowed by each of them
+func (p *ToolPolicy) allowLayers() []config.ToolPolicyLayer {
+	var layers []config.ToolPolicyLayer
+	for _, l := range p.layers {
+		if len(l.Allowed) > 0 {
+			layers = append(layers, l)
+		}
+	}
+	return layers
+}
+
+// Hidden returns why a tool is not offered to the model at all, or an empty
+// string. Tools with only command-scoped rules stay visible.
+func (p *ToolPolicy) Hidden(name string) string {
+	for _, l := range p.layers {
+		for _, text := range l.Exclude {
+			if r := parseToolRule(text); !r.scoped && r.matchesName(name) {
+				return fmt.Sprintf("tool %q is excluded by rule %q in %s", name, text, describeLayer(l))
+			}
+		}
+	}
+	for _, l := range p.allowLayers() {
+		listed := false
+		for _, text := range l.Allowed {
+			if parseToolRule(text).matchesName(name) {
+				listed = true
+				break
+			}
+		}
+		if !listed {
+			return fmt.Sprintf("tool %q is not in tools.allowed of %s", name, describeLayer(l))
+		}
+	}
+	return ""
+}
+
+// Check returns why a tool call is denied, or an empty string if it may run
+func (p *ToolPolicy) Check(name string, args map[string]interface{}) string {
+	if reason := p.Hidden(name); reason != "" {
+		return reason
+	}
+	command, _ := args["command"].(string)
+	command = strings.TrimSpace(command)
+	var cmds [][]string
+	var parseErr error
+	if name == "run_shell_command" {
+		cmds, parseErr = shellsafety.Commands(command)
+	}
+
+	for _, l := range p.layers {
+		for _, text := range l.Exclude {
+			r := parseToolRule(text)
+			if !r.scoped || !r.matchesName(name) {
+				continue
+			}
+			if matchesCommand(command, cmds, r.prefix) {
+				return fmt.Sprintf("command %q is excluded by rule %q in %s", command, text, describeLayer(l))
+			}
+		}
+	}
+
+	for _, l := range p.allowLayers() {
+		if reason := checkAllowed(l, name, command, cmds, parseErr); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// checkAllowed returns why the allowed list of layer l does not permit a
+// call, or an empty string
+func checkAllowed(l config.ToolPolicyLayer, name, command string, cmds [][]string, parseErr error) string {
+	var prefixes, redirectPrefixes []string
+	for _, text := range l.Allowed {
+		r := parseToolRule(text)
+		if !r.matchesName(name) {
+			continue
+		}
+		if !r.scoped {
+			return ""
+		}
+		prefixes = append(prefixes, r.prefix)
+		if r.redirects {
+			redirectPrefixes = append(redirectPrefixes, r.prefix)
+		}
+	}
+	if name != "run_shell_command" {
+		return fmt.Sprintf("tool %q is only allowed with a command prefix in tools.allowed of %s", name, describeLayer(l))
+	}
+	if parseErr != nil {
+		return fmt.Sprintf("command %q could not be parsed to check it against tools.allowed of %s: %v", command, describeLayer(l), parseErr)
+	}
+	// Output redirected to a file could overwrite anything, so every command
+	// must be allowed by a rule that permits it
+	redirects, _ := shellsafety.RedirectsToFile(command)
+	if redirects {
+		prefixes = redirectPrefixes
+		if len(prefixes) == 0 {
+			return fmt.Sprintf("command %q redirects output to a file, which no rule in tools.allowed of %s permits (add a rule ending in \">\")", command, describeLayer(l))
+		}
+	}
+	for _, argv := range cmds {
+		words := shellsafety.CommandWords(argv, false)
+		allowed := false
+		for _, prefix := range prefixes {
+			if shellsafety.HasPrefix(words, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed && redirects {
+			return fmt.Sprintf("command %q is not allowed: it redirects output to a file, and tools.allowed of %s only permits that for commands starting with %s",
+				strings.Join(argv, " "), describeLayer(l), quoteList(prefixes))
+		}
+		if !allowed {
+			return fmt.Sprintf("command %q is not allowed: tools.allowed of %s only permits shell commands starting with %s",
+				strings.Join(argv, " "), describeLayer(l), quoteList(prefixes))
+		}
+	}
+	return ""
+}
+
+// matchesCommand reports whether the command, or any command of its
+// pipelines and lists, starts with prefix
+func matchesCommand(command string, cmds [][]string, prefix string) bool {
+	if prefix == "" || strings.HasPrefix(command, prefix) {
+		return true
+	}
+	for _, argv := range cmds {
+		if shellsafety.HasPrefix(shellsafety.CommandWords(argv, true), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterDeclarations removes the tools the policy hides and returns notes
+// explaining the restrictions, for the system prompt
+func (p *ToolPolicy) FilterDeclarations(decls []api.FunctionDecl) ([]api.FunctionDecl, []string) {
+	if len(p.layers) == 0 {
+		return decls, nil
+	}
+	var filtered []api.FunctionDecl
+	var notes []string
+	for _, decl := range decls {
+		if reason := p.Hidden(decl.Name); reason != "" {
+			notes = append(notes, reason)
+			continue
+		}
+		filtered = append(filtered, decl)
+	}
+	for _, l := range p.allowLayers() {
+		var prefixes []string
+		unscoped := false
+		for _, text := range l.Allowed {
+			if r := parseToolRule(text); r.matchesName("run_shell_command") {
+				unscoped = unscoped || !r.scoped
+				prefixes = append(prefixes, r.prefix)
+			}
+		}
+		if !unscoped && len(prefixes) > 0 {
+			notes = append(notes, fmt.Sprintf("run_shell_command may only run commands starting with %s (tools.allowed of %s)", quoteList(prefixes), describeLayer(l)))
+		}
+	}
+	for _, l := range p.layers {
+		for _, text := range l.Exclude {
+			if r := parseToolRule(text); r.scoped && p.Hidden(r.name) == "" {
+				notes = append(notes, fmt.Sprintf("%s commands starting with %q are excluded by %s", r.name, r.prefix, describeLayer(l)))
+			}
+		}
+	}
+	return filtered, notes
+}
+
+func quoteList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, s := range items {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/api"
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+)
+
+func TestToolPolicy(t *testing.T) {
+	policy := NewToolPolicy(&config.Config{ToolPolicy: []config.ToolPolicyLayer{
+		{Source: "global settings", Allowed: []string{"read_file", "write_file", "run_shell_command", "github__*"}, Exclude: []string{"web_fetch", "run_shell_command(git push)"}},
+		{Source: "project settings", Allowed: []string{"read_file", "run_shell_command(git status)", "run_shell_command(ls)", "run_shell_command(go test >)", "github__*"}},
+		{Source: "extension docs", Exclude: []string{"github__delete_repo"}},
+	}})
+	shell := func(cmd string) map[string]interface{} { return map[string]interface{}{"command": cmd} }
+
+	tests := []struct {
+		name   string
+		args   map[string]interface{}
+		denied string // substring of the reason, "" if allowed
+	}{
+		{"read_file", nil, ""},
+		{"github__list_issues", nil, ""},
+		{"run_shell_command", shell("git status --short"), ""},
+		{"run_shell_command", shell("ls -la | git status"), ""},
+		{"web_fetch", nil, `excluded by rule "web_fetch" in global settings`},
+		{"write_file", nil, "not in tools.allowed of project settings"},
+		{"github__delete_repo", nil, "extension docs"},
+		{"run_shell_command", shell("git status && rm -rf x"), `command "rm -rf x" is not allowed`},
+		{"run_shell_command", shell("git statusx"), "not allowed"},
+		{"run_shell_command", shell("ls; git push origin"), `rule "run_shell_command(git push)"`},
+		{"run_shell_command", shell("git status 2>/dev/null >&2"), ""},
+		{"run_shell_command", shell("git status > status.txt"), "redirects output to a file"},
+		{"run_shell_command", shell("ls >> ~/.bashrc"), "redirects output to a file"},
+		{"run_shell_command", shell("bash -c 'ls > x'"), "not allowed"},
+		{"run_shell_command", shell("go test ./... > test.log"), ""},
+		{"run_shell_command", shell("go test ./... > test.log; ls"), `command "ls" is not allowed: it redirects`},
+		{"run_shell_command", shell("/usr/bin/git push"), `rule "run_shell_command(git push)"`},
+		{"run_shell_command", shell(`git p\ush`), `rule "run_shell_command(git push)"`},
+		{"run_shell_command", shell("git -C . push"), `rule "run_shell_command(git push)"`},
+		{"run_shell_command", shell("git --no-pager push"), `rule "run_shell_command(git push)"`},
+		{"run_shell_command", shell("/bin/ls -la"), ""},
+		{"run_shell_command", shell("git --no-pager status"), ""},
+		{"run_shell_command", shell("git -c core.pager=sh status"), "not allowed"},
+	}
+	for _, tt := range tests {
+		reason := policy.Check(tt.name, tt.args)
+		if tt.denied == "" && reason != "" {
+			t.Errorf("%s %v denied: %s", tt.name, tt.args, reason)
+		}
+		if tt.denied != "" && !strings.Contains(reason, tt.denied) {
+			t.Errorf("%s %v: reason %q does not contain %q", tt.name, tt.args, reason, tt.denied)
+		}
+	}
+
+	decls, notes := policy.FilterDeclarations([]api.FunctionDecl{{Name: "read_file"}, {Name: "web_fetch"}, {Name: "run_shell_command"}, {Name: "glob"}})
+	if len(decls) != 2 || decls[0].Name != "read_file" || decls[1].Name != "run_shell_command" {
+		t.Errorf("unexpected declarations: %v", decls)
+	}
+	if joined := strings.Join(notes, "\n"); !strings.Contains(joined, `"git status", "ls"`) || !strings.Contains(joined, `tool "glob"`) {
+		t.Errorf("unexpected notes:\n%s", joined)
+	}
+
+	widened := NewToolPolicy(&config.Config{ToolPolicy: []config.ToolPolicyLayer{
+		{Source: "global settings", Allowed: []string{"read_file"}, Exclude: []string{"run_shell_command(rm)", "run_shell_command(git push)"}},
+		{Source: "project settings", Allowed: []string{"*"}},
+	}})
+	if reason := widened.Check("run_shell_command", shell("rm -rf x")); !strings.Contains(reason, "not in tools.allowed of global settings") {
+		t.Errorf("project settings widened the global allowed list: %q", reason)
+	}
+	if reason := widened.Check("read_file", nil); reason != "" {
+		t.Errorf("read_file denied: %s", reason)
+	}
+
+	excluded := NewToolPolicy(&config.Config{ToolPolicy: []config.ToolPolicyLayer{
+		{Source: "global settings", Exclude: []string{"run_shell_command(rm)", "run_shell_command(git push)"}},
+	}})
+	for _, cmd := range []string{"/bin/rm x", `\rm x`, `git p\ush`, "git -C . push", "git --no-pager push", "ls && /usr/bin/git push"} {
+		if reason := excluded.Check("run_shell_command", shell(cmd)); !strings.Contains(reason, "excluded by rule") {
+			t.Errorf("%q not excluded: %q", cmd, reason)
+		}
+	}
+
+	if reason := NewToolPolicy(nil).Check("write_file", nil); reason != "" {
+		t.Errorf("empty policy denied a call: %s", reason)
+	}
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/api"
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+	"github.com/tomohiro-owada/gmn-gui/internal/sandbox"
+)
+
+// discoveryTimeout bounds tools.discoveryCommand
+const discoveryTimeout = 30 * time.Second
+
+// toolHandler runs one tool call
+type toolHandler func(ctx context.Context, env toolEnv, args map[string]interface{}) (ToolResult, error)
+
+// toolEnv is what a tool call runs against
+type toolEnv struct {
+	ws       *Workspace
+	settings *SettingsService
+}
+
+// ToolRegistry routes tool calls to the built-in tools and to the project
+// tools listed by tools.discoveryCommand and run by tools.callCommand
+type ToolRegistry struct {
+	mu          sync.Mutex
+	discovered  []api.FunctionDecl
+	callCommand string
+	err         error
+	discoveryOf string // settings the last discovery ran with
+}
+
+// NewToolRegistry creates a registry with only the built-in tools
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{}
+}
+
+// Discover runs tools.discoveryCommand in the working directory, inside the
+// sandbox box if it is set, and replaces the discovered tools. Tools named
+// like a built-in tool are skipped. On failure the previously discovered
+// tools are kept and the error is returned. The outcome is cached: the
+// command only runs again once the tools settings, working directory or
+// sandbox change.
+func (r *ToolRegistry) Discover(ctx context.Context, ws *Workspace, cfg *config.Config, box *sandbox.Options) error {
+	var command, callCommand string
+	if cfg != nil {
+		command, callCommand = cfg.Tools.DiscoveryCommand, cfg.Tools.CallCommand
+	}
+	key, _ := json.Marshal([]interface{}{command, callCommand, ws.WorkDir, box})
+
+	r.mu.Lock()
+	if r.discoveryOf == string(key) {
+		defer r.mu.Unlock()
+		return r.err
+	}
+	if command == "" {
+		r.discovered, r.callCommand, r.err, r.discoveryOf = nil, callCommand, nil, string(key)
+		r.mu.Unlock()
+		return nil
+	}
+	r.mu.Unlock()
+
+	runCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	var decls []api.FunctionDecl
+	stdout, err := runToolCommand(runCtx, command, ws.WorkDir, box, nil)
+	if err == nil {
+		decls, err = parseDiscoveredTools(stdout)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.callCommand = callCommand
+	if ctx.Err() == nil {
+		r.discoveryOf = string(key) // retry discoveries interrupted by the caller
+	}
+	if err != nil {
+		r.err = fmt.Errorf("tools.discoveryCommand: %w", err)
+		return r.err
+	}
+	r.discovered, r.err = decls, nil
+	return nil
+}
+
+// Discovered returns the declarations of the discovered tools and the error
+// of the last discovery, if it failed
+func (r *ToolRegistry) Discovered() ([]api.FunctionDecl, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]api.FunctionDecl(nil), r.discovered...), r.err
+}
+
+// Has reports whether name is a built-in or discovered tool
+func (r *ToolRegistry) Has(name string) bool {
+	if IsBuiltinTool(name) {
+		return true
+	}
+	_, ok := r.lookup(name)
+	return ok
+}
+
+func (r *ToolRegistry) lookup(name string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, decl := range r.discovered {
+		if decl.Name == name {
+			return r.callCommand, true
+		}
+	}
+	return "", false
+}
+
+// Execute runs a built-in or discovered tool. Calls denied by the tool
+// policy in settings are refused.
+func (r *ToolRegistry) Execute(ctx context.Context, ws *Workspace, name string, args map[string]interface{}, settings *SettingsService) (ToolResult, error) {
+	if IsBuiltinTool(name) {
+		return ExecuteBuiltinTool(ctx, ws, name, args, settings)
+	}
+	callCommand, ok := r.lookup(name)
+	if !ok {
+		return ToolResult{}, fmt.Errorf("unknown tool: %s", name)
+	}
+	if settings != nil {
+		if reason := NewToolPolicy(settings.GetConfig()).Check(name, args); reason != "" {
+			return ToolResult{}, fmt.Errorf("%s", reason)
+		}
+	}
+	return textResult(execDiscoveredTool(ctx, ws, callCommand, name, args, sandboxOptions(settings, ws)))
+}
+
+// execDiscoveredTool runs `<callCommand> <name>` with the JSON arguments on
+// standard input and returns its standard output
+func execDiscoveredTool(ctx context.Context, ws *Workspace, callCommand, name string, args map[string]interface{}, box *sandbox.Options) (string, error) {
+	if callCommand == "" {
+		return "", fmt.Errorf("tools.callCommand is not set, so discovered tool %q cannot be run", name)
+	}
+	input, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultShellTimeout)
+	defer cancel()
+	// Tool names are checked by parseDiscoveredTools, so single quotes are
+	// enough for both bash and PowerShell
+	out, err := runToolCommand(ctx, callCommand+" '"+name+"'", ws.WorkDir, box, input)
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return "(empty output)", nil
+	}
+	return string(out), nil
+}
+
+// runToolCommand runs a shell command with stdin and returns its standard
+// output. A non-zero exit status is an error that includes standard error.
+func runToolCommand(ctx context.Context, command, dir string, box *sandbox.Options, stdin []byte) ([]byte, error) {
+	cmd, cleanup, err := newShellCmd(ctx, command, dir, box)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	cmd.Cancel = func() error {
+		cleanup()
+		return killProcessGroup(cmd)
+	}
+	cmd.WaitDelay = 2 * time.Second
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%q did not finish: %w", command, ctx.Err())
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		return nil, fmt.Errorf("%q failed (%v): %s", command, err, msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// validToolName matches the function names the Gemini API accepts
+var validToolName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]{0,63}$`)
+
+// parseDiscoveredTools reads the output of a discovery command: a JSON array
+// of FunctionDeclarations, or of objects holding them in functionDeclarations
+// (or function_declarations), as printed for Gemini CLI
+func parseDiscoveredTools(data []byte) ([]api.FunctionDecl, error) {
+	type entry struct {
+		Name                 string            `json:"name"`
+		Description          string            `json:"description"`
+		Parameters           json.RawMessage   `json:"parameters"`
+		ParametersJSONSchema json.RawMessage   `json:"parametersJsonSchema"`
+		FunctionDeclarations []json.RawMessage `json:"functionDeclarations"`
+		FunctionDecls        []json.RawMessage `json:"function_declarations"`
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var single json.RawMessage
+		if json.Unmarshal(data, &single) != nil || len(single) == 0 || single[0] != '{' {
+			return nil, fmt.Errorf("expected a JSON array of function declarations: %w", err)
+		}
+		raw = []json.RawMessage{single}
+	}
+
+	var decls []api.FunctionDecl
+	seen := make(map[string]bool)
+	var add func(items []json.RawMessage) error
+	add = func(items []json.RawMessage) error {
+		for _, item := range items {
+			var e entry
+			if err := json.Unmarshal(item, &e); err != nil {
+				return fmt.Errorf("invalid function declaration: %w", err)
+			}
+			if nested := append(e.FunctionDeclarations, e.FunctionDecls...); len(nested) > 0 {
+				if err := add(nested); err != nil {
+					return err
+				}
+				continue
+			}
+			if !validToolName.MatchString(e.Name) {
+				return fmt.Errorf("invalid tool name %q", e.Name)
+			}
+			if strings.Contains(e.Name, "__") {
+				return fmt.Errorf("invalid tool name %q: \"__\" is reserved for MCP tools (server__tool)", e.Name)
+			}
+			if IsBuiltinTool(e.Name) || seen[e.Name] {
+				continue
+			}
+			seen[e.Name] = true
+			params := e.Parameters
+			if len(params) == 0 || string(params) == "null" {
+				params = e.ParametersJSONSchema
+			}
+			if len(params) == 0 || string(params) == "null" {
+				params = jsonRaw(`{"type": "object", "properties": {}}`)
+			}
+			decls = append(decls, api.FunctionDecl{Name: e.Name, Description: e.Description, Parameters: params})
+		}
+		return nil
+	}
+	if err := add(raw); err != nil {
+		return nil, err
+	}
+	return decls, nil
+}
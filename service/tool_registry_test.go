@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/config"
+)
+
+func TestParseDiscoveredTools(t *testing.T) {
+	decls, err := parseDiscoveredTools([]byte(`[
+		{"name": "deploy", "description": "Deploy", "parameters": {"type": "object"}},
+		{"functionDeclarations": [{"name": "lint"}, {"name": "read_file"}]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decls) != 2 || decls[0].Name != "deploy" || decls[1].Name != "lint" || !strings.Contains(string(decls[1].Parameters), "object") {
+		t.Errorf("unexpected declarations: %+v", decls)
+	}
+	for _, name := range []string{"bad name", "github__create_issue"} {
+		if _, err := parseDiscoveredTools([]byte(`[{"name": "` + name + `"}]`)); err == nil {
+			t.Errorf("invalid tool name %q accepted", name)
+		}
+	}
+}
+
+func TestDiscoveredTools(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "tools.sh")
+	os.WriteFile(script, []byte(`#!/bin/sh
+if [ "$1" = discover ]; then
+  echo '[{"name": "greet", "description": "Greets"}]'
+else
+  printf '%s:' "$2"; cat
+fi
+`), 0755)
+	cfg := &config.Config{Tools: config.ToolsConfig{DiscoveryCommand: script + " discover", CallCommand: script + " call"}}
+
+	r := NewToolRegistry()
+	ws := NewWorkspace(dir, nil, nil)
+	if err := r.Discover(context.Background(), ws, cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if decls, _ := r.Discovered(); len(decls) != 1 || !r.Has("greet") || r.Has("missing") {
+		t.Fatalf("unexpected tools: %+v", decls)
+	}
+	tr, err := r.Execute(context.Background(), ws, "greet", map[string]interface{}{"name": "gmn"}, nil)
+	if err != nil || tr.Output != `greet:{"name":"gmn"}` {
+		t.Errorf("got %q, %v", tr.Output, err)
+	}
+
+	// Discovery is cached until the settings change
+	os.WriteFile(script, []byte("#!/bin/sh\necho '[]'\n"), 0755)
+	if err := r.Discover(context.Background(), ws, cfg, nil); err != nil || !r.Has("greet") {
+		t.Errorf("discovery ran again with unchanged settings (err %v)", err)
+	}
+
+	cfg.Tools.DiscoveryCommand = "exit 3"
+	if err := r.Discover(context.Background(), ws, cfg, nil); err == nil || !r.Has("greet") {
+		t.Errorf("failed discovery should keep the previous tools (err %v)", err)
+	}
+}
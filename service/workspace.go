@@ -0,0 +1,202 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/glob"
+	"github.com/tomohiro-owada/gmn-gui/internal/ignore"
+)
+
+// Workspace confines file tools to the session's workspace roots (the working
+// directory plus any include directories) and extra directories allowed in
+// settings. Search tools walk Roots; all paths are stored symlink-resolved.
+type Workspace struct {
+	WorkDir string   // primary root; relative tool paths resolve against it
+	Roots   []string // workspace roots searched by glob/grep, WorkDir first
+	allowed []string // Roots plus allowlisted directories
+
+	matchers map[string]*ignore.Matcher // lazily created per root/allowed directory
+}
+
+// NewWorkspace creates a workspace rooted at workDir (the process cwd if empty)
+// with additional workspace roots and directories that are only allowed.
+func NewWorkspace(workDir string, includeDirs, allowedDirs []string) *Workspace {
+	if workDir == "" {
+		workDir, _ = os.Getwd()
+	}
+	ws := &Workspace{WorkDir: workDir}
+	for _, dir := range append([]string{workDir}, includeDirs...) {
+		if dir = ws.normalizeDir(dir); dir != "" {
+			ws.Roots = appendUnique(ws.Roots, dir)
+		}
+	}
+	ws.allowed = append(ws.allowed, ws.Roots...)
+	for _, dir := range allowedDirs {
+		if dir = ws.normalizeDir(dir); dir != "" {
+			ws.allowed = appendUnique(ws.allowed, dir)
+		}
+	}
+	return ws
+}
+
+func (w *Workspace) normalizeDir(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	dir = expandHome(dir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(w.WorkDir, dir)
+	}
+	return resolveSymlinks(filepath.Clean(dir))
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// SearchRoots returns the directories a search tool should walk: the tool's
+// dir_path argument if given (confined to the workspace), otherwise every root.
+func (w *Workspace) SearchRoots(args map[string]interface{}) ([]string, error) {
+	if d, ok := args["dir_path"].(string); ok && d != "" {
+		resolved, err := w.Resolve(d)
+		if err != nil {
+			return nil, err
+		}
+		return []string{resolved}, nil
+	}
+	return w.Roots, nil
+}
+
+// displayPath formats a search result: relative to the first search root and
+// absolute for any other root, so results from different roots stay unambiguous.
+func displayPath(roots []string, root, path string) string {
+	if root == roots[0] {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// Glob matches pattern like glob.Glob, but resolves the directory the walk
+// starts at first and rejects it if it falls outside every workspace root, so
+// patterns such as "/**" or "../**" never walk the rest of the filesystem.
+func (w *Workspace) Glob(root, pattern string, opts glob.Options) ([]string, error) {
+	base, err := w.Resolve(glob.Base(root, pattern))
+	if err != nil {
+		return nil, err
+	}
+	_, rest := glob.SplitPattern(pattern)
+	return glob.Glob(base, rest, opts)
+}
+
+// RelPath returns path relative to the workspace root containing it, or the
+// path unchanged if it is outside every root
+func (w *Workspace) RelPath(path string) string {
+	for _, root := range w.Roots {
+		if isWithin(root, path) {
+			if rel, err := filepath.Rel(root, path); err == nil {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return path
+}
+
+// Ignored reports whether an absolute path is excluded by the .gitignore and
+// .geminiignore files of the workspace root (or allowed directory) containing it.
+func (w *Workspace) Ignored(path string, isDir bool) bool {
+	root := ""
+	for _, dir := range w.allowed {
+		if isWithin(dir, path) && len(dir) > len(root) {
+			root = dir
+		}
+	}
+	if root == "" {
+		return false
+	}
+	m, ok := w.matchers[root]
+	if !ok {
+		if w.matchers == nil {
+			w.matchers = make(map[string]*ignore.Matcher)
+		}
+		m = ignore.New(root)
+		w.matchers[root] = m
+	}
+	return m.Match(path, isDir)
+}
+
+// Resolve turns a tool-supplied path into an absolute, symlink-resolved path and
+// rejects it if it falls outside every workspace root.
+func (w *Workspace) Resolve(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	p := expandHome(path)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(w.WorkDir, p)
+	}
+	resolved := resolveSymlinks(filepath.Clean(p))
+	if !w.Contains(resolved) {
+		return "", fmt.Errorf("path %q is outside the workspace. Allowed roots: %s", path, strings.Join(w.allowed, ", "))
+	}
+	return resolved, nil
+}
+
+// Contains reports whether an absolute, symlink-resolved path lies within an allowed directory
+func (w *Workspace) Contains(path string) bool {
+	for _, root := range w.allowed {
+		if isWithin(root, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWithin reports whether path equals root or is nested below it
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// resolveSymlinks evaluates symlinks in path. For paths that don't exist yet
+// (e.g. a file about to be written), the deepest existing ancestor is resolved
+// and the remaining components are appended unchanged.
+func resolveSymlinks(path string) string {
+	var missing []string
+	current := path
+	for {
+		if resolved, err := filepath.EvalSymlinks(current); err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return path
+		}
+		missing = append(missing, filepath.Base(current))
+		current = parent
+	}
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tomohiro-owada/gmn-gui/internal/glob"
+)
+
+func TestWorkspaceResolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644)
+	os.Symlink(outside, filepath.Join(root, "link"))
+
+	ws := NewWorkspace(root, nil, nil)
+
+	if p, err := ws.Resolve("src/main.go"); err != nil || !strings.HasSuffix(p, filepath.Join("src", "main.go")) {
+		t.Errorf("relative path: got %q, %v", p, err)
+	}
+	if _, err := ws.Resolve(filepath.Join(root, "new", "file.txt")); err != nil {
+		t.Errorf("non-existent path inside workspace rejected: %v", err)
+	}
+
+	for _, path := range []string{
+		"../escape.txt",
+		filepath.Join(outside, "secret.txt"),
+		"link/secret.txt",
+		"link/new.txt",
+	} {
+		_, err := ws.Resolve(path)
+		if err == nil {
+			t.Errorf("expected %q to be rejected", path)
+			continue
+		}
+		if !strings.Contains(err.Error(), "Allowed roots") {
+			t.Errorf("error for %q does not name the allowed roots: %v", path, err)
+		}
+	}
+
+	allowed := NewWorkspace(root, nil, []string{outside})
+	if _, err := allowed.Resolve("link/secret.txt"); err != nil {
+		t.Errorf("path in allowed directory rejected: %v", err)
+	}
+}
+
+func TestWorkspaceMultiRoot(t *testing.T) {
+	root := t.TempDir()
+	other := t.TempDir()
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main // needle"), 0o644)
+	os.WriteFile(filepath.Join(other, "lib.go"), []byte("package lib // needle"), 0o644)
+
+	ws := NewWorkspace(root, []string{other}, nil)
+	if _, err := ws.Resolve(filepath.Join(other, "lib.go")); err != nil {
+		t.Errorf("path in include directory rejected: %v", err)
+	}
+
+	otherLib := filepath.ToSlash(filepath.Join(resolveSymlinks(other), "lib.go"))
+
+	result, err := execGlob(ws, map[string]interface{}{"pattern": "*.go"})
+	if err != nil {
+		t.Fatalf("glob failed: %v", err)
+	}
+	if !strings.Contains(result, "main.go") || !strings.Contains(result, otherLib) {
+		t.Errorf("glob did not search every root:\n%s", result)
+	}
+
+	result, err = execGrepSearch(context.Background(), ws, map[string]interface{}{"pattern": "needle"})
+	if err != nil {
+		t.Fatalf("grep failed: %v", err)
+	}
+	if !strings.Contains(result, "main.go:1:") || !strings.Contains(result, otherLib+":1:") {
+		t.Errorf("grep did not search every root:\n%s", result)
+	}
+}
+
+func TestWorkspaceGlobConfined(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main"), 0o644)
+	os.WriteFile(filepath.Join(outside, "secret.go"), []byte("package secret"), 0o644)
+	os.Symlink(outside, filepath.Join(root, "link"))
+
+	ws := NewWorkspace(root, nil, nil)
+	for _, pattern := range []string{
+		"/**/*.go",
+		filepath.ToSlash(outside) + "/*.go",
+		"../**/*.go",
+		"link/*.go",
+	} {
+		if m, err := ws.Glob(root, pattern, glob.Options{}); err == nil {
+			t.Errorf("%q walked outside the workspace: %v", pattern, m)
+		}
+	}
+
+	m, err := ws.Glob(root, "*.go", glob.Options{})
+	if err != nil || len(m) != 1 || filepath.Base(m[0]) != "main.go" {
+		t.Errorf("pattern inside the workspace: got %v, %v", m, err)
+	}
+}